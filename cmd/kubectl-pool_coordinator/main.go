@@ -0,0 +1,115 @@
+// kubectl-pool_coordinator is a kubectl plugin inspecting pool coordination state, deciding
+// in-process as the pool-coordinator controller and webhook do:
+//
+//	kubectl pool-coordinator pools
+//	kubectl pool-coordinator node <name>
+//	kubectl pool-coordinator explain pod <namespace/name>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/client"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/inspect"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
+
+const usage = `Inspect pool coordination state.
+
+Usage:
+  kubectl pool-coordinator [flags] pools
+  kubectl pool-coordinator [flags] node <name>
+  kubectl pool-coordinator [flags] explain pod <namespace/name>
+
+Flags:
+`
+
+func main() {
+	fs := flag.NewFlagSet("kubectl-pool_coordinator", flag.ExitOnError)
+	kubeconfig := fs.String("kubeconfig", "", "path to the kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config")
+	configFile := fs.String("config", "", "path to the pool-coordinator configuration file, read from the ConfigMap if empty")
+	defaults := fs.Bool("defaults", false, "decide on the default configuration instead of the ConfigMap")
+	namespace := fs.String("namespace", "kube-system", "namespace pool-coordinator is installed in")
+	configMap := fs.String("configmap", "pool-coordinator-config", "ConfigMap holding the pool-coordinator configuration")
+	as := fs.String("as", "system:serviceaccount:kube-system:node-controller", "user evicting the pod, for explain")
+	asGroups := fs.String("as-group", "system:serviceaccounts,system:serviceaccounts:kube-system", "comma separated groups of the user evicting the pod, for explain")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+
+	// informers and listers log as they sync, keep the output to the answer
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	klogFlags.Set("logtostderr", "false")
+	klogFlags.Set("stderrthreshold", "FATAL")
+	klog.SetOutput(io.Discard)
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c, dc, err := client.GetClientsFromKubeconfig(*kubeconfig)
+	if err != nil {
+		fail(err)
+	}
+	var cfg *config.Config
+	switch {
+	case *configFile != "" && *defaults:
+		fail(fmt.Errorf("-config and -defaults are mutually exclusive"))
+	case *configFile != "":
+		cfg, err = config.Parse(mustRead(*configFile))
+	case *defaults:
+		cfg = config.Default()
+	default:
+		cfg, err = inspect.LoadConfig(c, *namespace, *configMap)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	switch {
+	case args[0] == "pools" && len(args) == 1:
+		err = inspect.Load(c, dc, cfg).PrintPools(os.Stdout)
+	case args[0] == "node" && len(args) == 2:
+		err = inspect.Load(c, dc, cfg).PrintNode(os.Stdout, args[1])
+	case args[0] == "explain" && len(args) == 3 && args[1] == "pod":
+		parts := strings.SplitN(args[2], "/", 2)
+		if len(parts) != 2 {
+			fail(fmt.Errorf("pod must be given as namespace/name, not %q", args[2]))
+		}
+		user := authenticationv1.UserInfo{Username: *as}
+		if *asGroups != "" {
+			user.Groups = strings.Split(*asGroups, ",")
+		}
+		err = inspect.Load(c, dc, cfg).Explain(os.Stdout, parts[0], parts[1], user)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func mustRead(name string) []byte {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fail(err)
+	}
+	return data
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
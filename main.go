@@ -1,8 +1,110 @@
 package main
 
-import poolcoordinator "github.com/openyurtio/openyurt/pkg/controller/poolcoordinator"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	poolcoordinator "github.com/openyurtio/openyurt/pkg/controller/poolcoordinator"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/simulate"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		runSimulate(os.Args[2:])
+		return
+	}
+
+	configFile := flag.String("config", config.DefaultConfigFile, "path to the configuration file")
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
 	nc := poolcoordinator.GetController()
-	nc.Run()
+	nc.Run(cfg)
+}
+
+// runSimulate answers an admission review offline, on the manifests of a directory
+func runSimulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configFile := fs.String("config", "", "path to the configuration file, defaults are used if empty")
+	dir := fs.String("dir", ".", "directory of Node, Lease, Pod, PodDisruptionBudget and NodePool manifests")
+	reviewFile := fs.String("review", "", "AdmissionReview to answer, instead of -operation")
+	operation := fs.String("operation", "evict", "operation on -pod to answer: evict, delete, create or update")
+	podName := fs.String("pod", "", "pod of -operation, as namespace/name, from the manifests")
+	as := fs.String("as", "system:serviceaccount:kube-system:node-controller", "user of -operation")
+	asGroups := fs.String("as-group", "system:serviceaccounts,system:serviceaccounts:kube-system", "comma separated groups of the user of -operation")
+	at := fs.String("at", "", "RFC3339 time the manifests were taken at, leases are moved to now as if renewed since then")
+	trustRenewTime := fs.Bool("trust-renew-time", false, "take the renew time of leases without managed fields as when they were renewed, despite clock skew of nodes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pool-coordinator-controller simulate [flags]")
+		fs.PrintDefaults()
+	}
+	klog.InitFlags(fs)
+	fs.Parse(args)
+
+	cfg := config.Default()
+	var err error
+	if *configFile != "" {
+		if cfg, err = config.Load(*configFile); err != nil {
+			klog.Fatal(err)
+		}
+	}
+	fixtures, err := simulate.LoadDir(*dir)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	if *at != "" {
+		taken, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			klog.Fatalf("invalid -at: %v", err)
+		}
+		fixtures.Shift(time.Since(taken))
+	}
+	st, err := fixtures.State(cfg, *trustRenewTime)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	var review *admissionv1.AdmissionReview
+	if *reviewFile != "" {
+		review, err = simulate.LoadReview(*reviewFile)
+	} else {
+		parts := strings.SplitN(*podName, "/", 2)
+		if len(parts) != 2 {
+			klog.Fatalf("-pod must be given as namespace/name, not %q", *podName)
+		}
+		pod, perr := st.Pods.Pods(parts[0]).Get(parts[1])
+		if perr != nil {
+			klog.Fatal(perr)
+		}
+		user := authenticationv1.UserInfo{Username: *as}
+		if *asGroups != "" {
+			user.Groups = strings.Split(*asGroups, ",")
+		}
+		review, err = simulate.NewReview(*operation, pod, user)
+	}
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	name, out, runErr := simulate.Run(st, review)
+	// the webhook answers errors too, print its answer as the API server would get it
+	if err := simulate.Print(os.Stdout, name, out); err != nil {
+		klog.Fatal(err)
+	}
+	if runErr != nil {
+		klog.Errorf("webhook %s failed: %v", name, runErr)
+		klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+	}
 }
@@ -0,0 +1,94 @@
+package poolcoordinator
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the remaining autonomy of a node without heartbeat is refreshed this often
+	autonomyRefreshInterval = 30 * time.Second
+)
+
+// desiredAutonomyRemaining returns the autonomy-remaining annotation node should carry,
+// empty if none, and when to check again
+func (nc *Controller) desiredAutonomyRemaining(node *corev1.Node) (string, time.Duration) {
+	// nodes in autonomy without limit, or not at all, never run out of it
+	d, ok := nc.pools.AutonomyDuration(node)
+	if !ok || d == 0 {
+		return "", 0
+	}
+	expiry, known := nc.liveness.Expiry(node.Name)
+	if !known {
+		return "", 0
+	}
+	if left := time.Until(expiry); left > 0 {
+		// heartbeat is not lost yet, check again once it is due
+		return "", left
+	}
+	remaining := d - time.Since(expiry)
+	if remaining <= 0 {
+		return "0s", 0
+	}
+	next := autonomyRefreshInterval
+	if remaining < next {
+		next = remaining
+	}
+	return remaining.Truncate(time.Second).String(), next
+}
+
+// reconcileAutonomy exposes how long node name has left in autonomy since its heartbeat was lost
+func (nc *Controller) reconcileAutonomy(name string) error {
+	node, err := nc.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	desired, next := nc.desiredAutonomyRemaining(node)
+	if next > 0 {
+		nc.queue.AddAfter(name, next)
+	}
+	current, annotated := node.Annotations[constant.AnnotationKeyAutonomyRemaining]
+	if current == desired && annotated == (desired != "") {
+		return nil
+	}
+	if desired == "0s" {
+		klog.Infof("autonomy of node %s expired without heartbeat", name)
+	}
+	var value *string
+	if desired != "" {
+		value = &desired
+	}
+	return nc.patchNodeAnnotations(name, "", map[string]*string{constant.AnnotationKeyAutonomyRemaining: value})
+}
+
+// patchNodeAnnotations sets annotations of node name, removing those set to nil. A non-empty
+// resourceVersion makes the patch fail with a conflict if node changed meanwhile.
+func (nc *Controller) patchNodeAnnotations(name, resourceVersion string, annotations map[string]*string) error {
+	metadata := map[string]interface{}{
+		"annotations": annotations,
+	}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
+	data, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+	_, err = nc.client.CoreV1().Nodes().Patch(context.TODO(), name, types.MergePatchType, data, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
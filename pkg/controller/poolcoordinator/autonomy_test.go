@@ -0,0 +1,78 @@
+package poolcoordinator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileAutonomy(t *testing.T) {
+	lost := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "20m"},
+		},
+	}
+	alive := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node2",
+			Annotations: map[string]string{
+				constant.AnnotationKeyNodeAutonomyDuration: "20m",
+				constant.AnnotationKeyAutonomyRemaining:    "1m0s",
+			},
+		},
+	}
+	unlimited := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node3",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"},
+		},
+	}
+	nc := newTestController(t, lost, alive, unlimited)
+	defer nc.queue.ShutDown()
+
+	duration := int32(40)
+	for name, renewed := range map[string]time.Time{
+		"node1": time.Now().Add(-5 * time.Minute),
+		"node2": time.Now(),
+		"node3": time.Now().Add(-time.Hour),
+	} {
+		nc.liveness.ObserveRenewal(&coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: coordv1.LeaseSpec{
+				LeaseDurationSeconds: &duration,
+				RenewTime:            &metav1.MicroTime{Time: renewed},
+			},
+		}, renewed)
+	}
+
+	tests := []struct {
+		node   string
+		prefix string
+		exists bool
+	}{
+		// lost 4m20s ago, about 15m40s left
+		{"node1", "15m", true},
+		{"node2", "", false},
+		{"node3", "", false},
+	}
+	for _, tt := range tests {
+		if err := nc.reconcileAutonomy(tt.node); err != nil {
+			t.Fatal(err)
+		}
+		got, err := nc.client.CoreV1().Nodes().Get(context.TODO(), tt.node, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, ok := got.Annotations[constant.AnnotationKeyAutonomyRemaining]
+		if ok != tt.exists || !strings.HasPrefix(v, tt.prefix) {
+			t.Errorf("%s: expect remaining %q..., but %q returned", tt.node, tt.prefix, v)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// secret keys, tls.crt and tls.key are compatible with kubernetes.io/tls secrets
+	CACertKey = "ca.crt"
+	CAKeyKey  = "ca.key"
+	CertKey   = corev1.TLSCertKey
+	KeyKey    = corev1.TLSPrivateKeyKey
+
+	checkInterval = time.Hour
+)
+
+// Manager keeps the webhook CA and serving certificate in a Secret, patches the CA bundle
+// into the webhook configurations and rotates both certificates before they expire
+type Manager struct {
+	client  kubernetes.Interface
+	certs   config.CertificatesConfig
+	webhook config.WebhookConfig
+
+	lock     sync.RWMutex
+	current  *tls.Certificate
+	caBundle []byte
+}
+
+func NewManager(client kubernetes.Interface, cfg *config.Config) *Manager {
+	return &Manager{
+		client:  client,
+		certs:   cfg.Certificates,
+		webhook: cfg.Webhook,
+	}
+}
+
+// DNSNames returns the names the webhook service is reached by
+func (m *Manager) DNSNames() []string {
+	svc, ns := m.webhook.ServiceName, m.webhook.Namespace
+	return []string{
+		fmt.Sprintf("%s.%s.svc", svc, ns),
+		fmt.Sprintf("%s.%s.svc.cluster.local", svc, ns),
+		fmt.Sprintf("%s.%s", svc, ns),
+		svc,
+	}
+}
+
+// GetCertificate serves the current certificate, to be used as tls.Config.GetCertificate
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.current == nil {
+		return nil, fmt.Errorf("serving certificate not ready")
+	}
+	return m.current, nil
+}
+
+// CABundle returns the PEM encoded CAs the serving certificate is trusted by
+func (m *Manager) CABundle() []byte {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.caBundle
+}
+
+// Ensure makes the secret hold valid certificates, rotating them when needed,
+// and patches the CA bundle into the webhook configurations
+func (m *Manager) Ensure() error {
+	var secret *corev1.Secret
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		secret, err = m.ensureSecret()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	pair, err := tls.X509KeyPair(secret.Data[CertKey], secret.Data[KeyKey])
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.current = &pair
+	m.caBundle = secret.Data[CACertKey]
+	m.lock.Unlock()
+
+	return m.patchCABundle(secret.Data[CACertKey])
+}
+
+// Run checks certificates for expiry periodically until stopCh is closed
+func (m *Manager) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := m.Ensure(); err != nil {
+			klog.Errorf("could not ensure webhook certificates: %v", err)
+		}
+	}, checkInterval, stopCh)
+}
+
+func (m *Manager) ensureSecret() (*corev1.Secret, error) {
+	secrets := m.client.CoreV1().Secrets(m.webhook.Namespace)
+	secret, err := secrets.Get(context.TODO(), m.certs.SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.certs.SecretName,
+				Namespace: m.webhook.Namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+		if _, err := m.rotate(secret); err != nil {
+			return nil, err
+		}
+		klog.Infof("creating webhook certificates in secret %s/%s", m.webhook.Namespace, m.certs.SecretName)
+		created, err := secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// another replica won the race, retry with its certificates
+			return nil, apierrors.NewConflict(corev1.Resource("secrets"), m.certs.SecretName, err)
+		}
+		return created, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret = secret.DeepCopy()
+	changed, err := m.rotate(secret)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return secret, nil
+	}
+	klog.Infof("rotating webhook certificates in secret %s/%s", m.webhook.Namespace, m.certs.SecretName)
+	return secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+}
+
+// rotate renews the certificates in secret that are missing, invalid or about to expire
+func (m *Manager) rotate(secret *corev1.Secret) (bool, error) {
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	renewBefore := m.certs.RenewBefore.Duration
+	changed := false
+
+	ca := &KeyPair{Cert: secret.Data[CACertKey], Key: secret.Data[CAKeyKey]}
+	if caCert, _, err := ca.parse(); err != nil || time.Until(caCert.NotAfter) < renewBefore {
+		newCA, err := NewCA("pool-coordinator-webhook-ca", m.certs.CAValidity.Duration)
+		if err != nil {
+			return false, err
+		}
+		// keep trusting the old CA until it expires, so current serving certs stay valid
+		secret.Data[CACertKey] = Bundle(newCA.Cert, ca.Cert)
+		secret.Data[CAKeyKey] = newCA.Key
+		ca = newCA
+		changed = true
+	}
+
+	cert, err := ParseCert(secret.Data[CertKey])
+	if changed || err != nil || time.Until(cert.NotAfter) < renewBefore || !m.covers(cert.DNSNames) {
+		serving, err := NewServingCert(ca, m.DNSNames(), m.certs.CertValidity.Duration)
+		if err != nil {
+			return false, err
+		}
+		secret.Data[CertKey] = serving.Cert
+		secret.Data[KeyKey] = serving.Key
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// covers tells whether a certificate for dnsNames is good for the webhook service
+func (m *Manager) covers(dnsNames []string) bool {
+	names := make(map[string]bool, len(dnsNames))
+	for _, n := range dnsNames {
+		names[n] = true
+	}
+	for _, n := range m.DNSNames() {
+		if !names[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) patchCABundle(caBundle []byte) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.patchMutatingCABundle(caBundle); err != nil {
+			return err
+		}
+		return m.patchValidatingCABundle(caBundle)
+	})
+}
+
+func (m *Manager) patchMutatingCABundle(caBundle []byte) error {
+	client := m.client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	mwc, err := client.Get(context.TODO(), m.webhook.MutatingWebhookConfiguration, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Warningf("mutating webhook configuration %s not found, caBundle not patched", m.webhook.MutatingWebhookConfiguration)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	mwc = mwc.DeepCopy()
+	for i := range mwc.Webhooks {
+		if !bytes.Equal(mwc.Webhooks[i].ClientConfig.CABundle, caBundle) {
+			mwc.Webhooks[i].ClientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	klog.Infof("patching caBundle of mutating webhook configuration %s", mwc.Name)
+	_, err = client.Update(context.TODO(), mwc, metav1.UpdateOptions{})
+	return err
+}
+
+func (m *Manager) patchValidatingCABundle(caBundle []byte) error {
+	client := m.client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	vwc, err := client.Get(context.TODO(), m.webhook.ValidatingWebhookConfiguration, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Warningf("validating webhook configuration %s not found, caBundle not patched", m.webhook.ValidatingWebhookConfiguration)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	vwc = vwc.DeepCopy()
+	for i := range vwc.Webhooks {
+		if !bytes.Equal(vwc.Webhooks[i].ClientConfig.CABundle, caBundle) {
+			vwc.Webhooks[i].ClientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	klog.Infof("patching caBundle of validating webhook configuration %s", vwc.Name)
+	_, err = client.Update(context.TODO(), vwc, metav1.UpdateOptions{})
+	return err
+}
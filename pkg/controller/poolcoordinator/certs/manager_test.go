@@ -0,0 +1,126 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Certificates.SelfManaged = true
+	cfg.Webhook.Namespace = "kube-system"
+	return cfg
+}
+
+func TestManagerEnsure(t *testing.T) {
+	cfg := testConfig()
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: cfg.Webhook.MutatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "m"}},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: cfg.Webhook.ValidatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "v"}},
+		},
+	)
+	m := NewManager(client, cfg)
+
+	if err := m.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.CoreV1().Secrets(cfg.Webhook.Namespace).Get(context.TODO(), cfg.Certificates.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// serving cert is signed by the CA and valid for the service name
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(secret.Data[CACertKey])
+	cert, err := ParseCert(secret.Data[CertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName: "pool-coordinator-webhook.kube-system.svc",
+		Roots:   pool,
+	})
+	if err != nil {
+		t.Errorf("expect serving cert verified, but %v returned", err)
+	}
+
+	mwc, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.TODO(), cfg.Webhook.MutatingWebhookConfiguration, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mwc.Webhooks[0].ClientConfig.CABundle, secret.Data[CACertKey]) {
+		t.Errorf("expect caBundle patched into mutating webhook configuration")
+	}
+	vwc, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), cfg.Webhook.ValidatingWebhookConfiguration, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(vwc.Webhooks[0].ClientConfig.CABundle, secret.Data[CACertKey]) {
+		t.Errorf("expect caBundle patched into validating webhook configuration")
+	}
+
+	if _, err := m.GetCertificate(nil); err != nil {
+		t.Errorf("expect serving certificate, but %v returned", err)
+	}
+
+	// certificates are reused while valid
+	if err := m.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := client.CoreV1().Secrets(cfg.Webhook.Namespace).Get(context.TODO(), cfg.Certificates.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Data[CertKey], secret.Data[CertKey]) {
+		t.Errorf("expect serving cert reused, but it was rotated")
+	}
+}
+
+func TestManagerRotate(t *testing.T) {
+	cfg := testConfig()
+	client := fake.NewSimpleClientset()
+	m := NewManager(client, cfg)
+	if err := m.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.CoreV1().Secrets(cfg.Webhook.Namespace).Get(context.TODO(), cfg.Certificates.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a serving cert expiring within renewBefore is renewed by the same CA
+	ca := &KeyPair{Cert: secret.Data[CACertKey], Key: secret.Data[CAKeyKey]}
+	expiring, err := NewServingCert(ca, m.DNSNames(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Data[CertKey] = expiring.Cert
+	secret.Data[KeyKey] = expiring.Key
+
+	changed, err := m.rotate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Errorf("expect %v, but %v returned", true, changed)
+	}
+	if bytes.Equal(secret.Data[CertKey], expiring.Cert) {
+		t.Errorf("expect serving cert renewed")
+	}
+	if !bytes.Equal(secret.Data[CACertKey], ca.Cert) {
+		t.Errorf("expect CA kept")
+	}
+}
@@ -0,0 +1,103 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/metrics"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	reloadInterval = 10 * time.Second
+)
+
+// Watcher serves a key pair from files and reloads it when the files change, e.g. when
+// cert-manager or the kubelet rotates a mounted Secret. An invalid pair is never served;
+// the last good one is kept until valid files show up.
+type Watcher struct {
+	certFile string
+	keyFile  string
+
+	lock    sync.RWMutex
+	current *tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// NewWatcher loads the key pair from certFile and keyFile
+func NewWatcher(certFile, keyFile string) (*Watcher, error) {
+	w := &Watcher{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetCertificate serves the current certificate, to be used as tls.Config.GetCertificate
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.current == nil {
+		return nil, fmt.Errorf("serving certificate not ready")
+	}
+	return w.current, nil
+}
+
+// Run polls the files for changes until stopCh is closed
+func (w *Watcher) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		reloaded, err := w.reload()
+		if err != nil {
+			klog.Errorf("could not reload serving certificate, keep serving the last good one: %v", err)
+			metrics.CertificateReloads.WithLabelValues("error").Inc()
+			return
+		}
+		if reloaded {
+			klog.Infof("serving certificate reloaded from %s", w.certFile)
+			metrics.CertificateReloads.WithLabelValues("success").Inc()
+		}
+	}, reloadInterval, stopCh)
+}
+
+// reload swaps in the key pair on disk if it changed and is valid
+func (w *Watcher) reload() (bool, error) {
+	certPEM, err := os.ReadFile(w.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyPEM, err := os.ReadFile(w.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	w.lock.RLock()
+	unchanged := bytes.Equal(certPEM, w.certPEM) && bytes.Equal(keyPEM, w.keyPEM)
+	w.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	// cert and key may be caught in the middle of an update, in which case they do not
+	// match; the next poll picks up the complete pair
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.current = &pair
+	w.certPEM = certPEM
+	w.keyPEM = keyPEM
+	return true, nil
+}
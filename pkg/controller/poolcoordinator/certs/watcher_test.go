@@ -0,0 +1,90 @@
+package certs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePair(t *testing.T, certFile, keyFile string, kp *KeyPair) {
+	if err := os.WriteFile(certFile, kp.Cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, kp.Key, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	ca, err := NewCA("test-ca", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := NewServingCert(ca, []string{"first"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewServingCert(ca, []string{"second"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePair(t, certFile, keyFile, first)
+	w, err := NewWatcher(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mismatched cert and key are rejected, the last good pair is kept
+	writePair(t, certFile, keyFile, &KeyPair{Cert: second.Cert, Key: first.Key})
+	if _, err := w.reload(); err == nil {
+		t.Errorf("expect error, but nil returned")
+	}
+	served, err := w.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCert(first.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.Certificate[0], cert.Raw) {
+		t.Errorf("expect first certificate served")
+	}
+
+	// a complete new pair is swapped in
+	writePair(t, certFile, keyFile, second)
+	reloaded, err := w.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded {
+		t.Errorf("expect %v, but %v returned", true, reloaded)
+	}
+	served, err = w.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = ParseCert(second.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(served.Certificate[0], cert.Raw) {
+		t.Errorf("expect second certificate served")
+	}
+
+	// unchanged files are not reloaded
+	reloaded, err = w.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded {
+		t.Errorf("expect %v, but %v returned", false, reloaded)
+	}
+}
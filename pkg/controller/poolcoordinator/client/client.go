@@ -3,6 +3,7 @@ package client
 import (
 	"flag"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -39,3 +40,39 @@ func GetClientFromCluster() *kubernetes.Clientset {
 
 	return clientset
 }
+
+func GetDynamicClientFromCluster() dynamic.Interface {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	return client
+}
+
+// GetClientsFromKubeconfig returns clients for the cluster of kubeconfig file kubeconfig, or
+// of the usual kubeconfig locations and $KUBECONFIG if it is empty
+func GetClientsFromKubeconfig(kubeconfig string) (kubernetes.Interface, dynamic.Interface, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return clientset, dynamicClient, nil
+}
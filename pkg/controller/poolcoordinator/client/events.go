@@ -0,0 +1,33 @@
+package client
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventComponent = "pool-coordinator"
+	// similar events on an object beyond this many within the interval are aggregated into one
+	eventAggregateMax      = 5
+	eventAggregateInterval = 600
+	// events on an object are let through in bursts, then one every 5 minutes
+	eventBurst = 10
+	eventQPS   = 1. / 300
+)
+
+// NewEventRecorder returns a recorder writing events through c. Repeated events for the same
+// pod or node are aggregated and rate limited, so a pod denied over and over does not flood
+// the api-server.
+func NewEventRecorder(c kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		MaxEvents:            eventAggregateMax,
+		MaxIntervalInSeconds: eventAggregateInterval,
+		BurstSize:            eventBurst,
+		QPS:                  eventQPS,
+	})
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+}
@@ -0,0 +1,125 @@
+package poolcoordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// reasons of the delegated condition
+	conditionReasonDelegated     = "HeartbeatDelegated"
+	conditionReasonNotDelegated  = "HeartbeatDirect"
+	conditionReasonLeaseNotFound = "LeaseNotFound"
+)
+
+// desiredDelegatedCondition returns the delegated condition node name should carry, from the
+// delegate-heartbeat annotation of its lease. While delegated, the message tells how far the
+// streak of delegated heartbeats is from tainting the node; it changes at most threshold
+// times a streak, as the count stops there.
+func (nc *Controller) desiredDelegatedCondition(name string) (*corev1.NodeCondition, error) {
+	lease, err := nc.leaseLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return &corev1.NodeCondition{
+			Type:    constant.NodeConditionDelegated,
+			Status:  corev1.ConditionUnknown,
+			Reason:  conditionReasonLeaseNotFound,
+			Message: "node lease not found",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isDelegated(lease) {
+		return &corev1.NodeCondition{
+			Type:    constant.NodeConditionDelegated,
+			Status:  corev1.ConditionFalse,
+			Reason:  conditionReasonNotDelegated,
+			Message: "node renews its lease by itself",
+		}, nil
+	}
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	message := fmt.Sprintf("node lease is renewed by pool coordinator on behalf of holder %q", holder)
+	if count, since, ok := ldc.Streak(name); ok {
+		if ldc.window > 0 {
+			message += fmt.Sprintf(", delegated since %s, tainted after %s", since.UTC().Format(time.RFC3339), ldc.window)
+		} else {
+			message += fmt.Sprintf(", %d of %d delegated heartbeats since %s", count, ldc.threshold, since.UTC().Format(time.RFC3339))
+		}
+	}
+	return &corev1.NodeCondition{
+		Type:    constant.NodeConditionDelegated,
+		Status:  corev1.ConditionTrue,
+		Reason:  conditionReasonDelegated,
+		Message: message,
+	}, nil
+}
+
+// reconcileCondition makes the delegated condition of node name reflect its lease. Nodes whose
+// heartbeat never was delegated get no condition at all.
+func (nc *Controller) reconcileCondition(name string) error {
+	node, err := nc.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	desired, err := nc.desiredDelegatedCondition(name)
+	if err != nil {
+		return err
+	}
+
+	var current *corev1.NodeCondition
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == constant.NodeConditionDelegated {
+			current = &node.Status.Conditions[i]
+			break
+		}
+	}
+	switch {
+	case current == nil && desired.Status != corev1.ConditionTrue:
+		return nil
+	case current != nil && current.Status == desired.Status && current.Reason == desired.Reason &&
+		current.Message == desired.Message:
+		return nil
+	}
+
+	now := metav1.Now()
+	desired.LastHeartbeatTime = now
+	desired.LastTransitionTime = now
+	if current != nil && current.Status == desired.Status {
+		desired.LastTransitionTime = current.LastTransitionTime
+	}
+	klog.Infof("condition %s of node %s is %s: %s", desired.Type, name, desired.Status, desired.Reason)
+	return nc.patchNodeCondition(name, desired)
+}
+
+// patchNodeCondition sets condition on the status of node name, conditions merge by type
+func (nc *Controller) patchNodeCondition(name string, condition *corev1.NodeCondition) error {
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []*corev1.NodeCondition{condition},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = nc.client.CoreV1().Nodes().Patch(context.TODO(), name, types.StrategicMergePatchType, data, metav1.PatchOptions{}, "status")
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package poolcoordinator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func delegatedCondition(t *testing.T, nc *Controller, name string) *corev1.NodeCondition {
+	node, err := nc.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == constant.NodeConditionDelegated {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestReconcileCondition(t *testing.T) {
+	nodes := []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+	}
+	nc := newTestController(t, nodes...)
+	defer nc.queue.ShutDown()
+
+	holder := "node1"
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	leases := []*coordv1.Lease{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node1",
+				Namespace:   corev1.NamespaceNodeLease,
+				Annotations: map[string]string{constant.DelegateHeartBeat: "true"},
+			},
+			Spec: coordv1.LeaseSpec{HolderIdentity: &holder},
+		},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2", Namespace: corev1.NamespaceNodeLease}},
+	}
+	for _, l := range leases {
+		if err := indexer.Add(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nc.leaseLister = leaselisterv1.NewLeaseLister(indexer).Leases(corev1.NamespaceNodeLease)
+
+	ldc.Inc("node1")
+	ldc.Inc("node1")
+	for _, name := range []string{"node1", "node2"} {
+		if err := nc.reconcileCondition(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := delegatedCondition(t, nc, "node1")
+	if c == nil || c.Status != corev1.ConditionTrue || c.Reason != conditionReasonDelegated {
+		t.Errorf("expect %s condition, but %+v returned", conditionReasonDelegated, c)
+	}
+	if want := fmt.Sprintf("2 of %d delegated heartbeats since", constant.LeaseDelegationThreshold); c != nil && !strings.Contains(c.Message, want) {
+		t.Errorf("expect %q in message, but %q returned", want, c.Message)
+	}
+	// a node never delegated carries no condition
+	if c := delegatedCondition(t, nc, "node2"); c != nil {
+		t.Errorf("expect no condition, but %+v returned", c)
+	}
+}
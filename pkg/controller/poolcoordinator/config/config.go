@@ -0,0 +1,477 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultConfigFile is where the chart mounts the pool-coordinator ConfigMap
+	DefaultConfigFile = "/etc/pool-coordinator/config.yaml"
+)
+
+// Config holds the tunables of pool-coordinator controller and webhook
+type Config struct {
+	DryRun         DryRunConfig         `json:"dryRun"`
+	Tolerations    TolerationsConfig    `json:"tolerations"`
+	Webhook        WebhookConfig        `json:"webhook"`
+	Certificates   CertificatesConfig   `json:"certificates"`
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+	Delegation     DelegationConfig     `json:"delegation"`
+	Liveness       LivenessConfig       `json:"liveness"`
+	Quorum         QuorumConfig         `json:"quorum"`
+	Identities     []IdentityConfig     `json:"identities"`
+	Partition      PartitionConfig      `json:"partition"`
+	Throttle       ThrottleConfig       `json:"throttle"`
+}
+
+// DryRunConfig controls audit-only mode of the validating webhook. In dry-run mode
+// deletions are fully validated, but always allowed; denials are only reported.
+// A namespace override takes precedence over a pool override, which takes
+// precedence over the global switch.
+type DryRunConfig struct {
+	Enabled    bool            `json:"enabled"`
+	Namespaces map[string]bool `json:"namespaces,omitempty"`
+	Pools      map[string]bool `json:"pools,omitempty"`
+}
+
+// TolerationsConfig is the set of tolerations the mutating webhook injects into pods
+// bound to autonomous nodes. As for dry-run, a namespace variant takes precedence
+// over a pool variant, which takes precedence over the default set.
+type TolerationsConfig struct {
+	Default    []corev1.Toleration            `json:"default,omitempty"`
+	Namespaces map[string][]corev1.Toleration `json:"namespaces,omitempty"`
+	Pools      map[string][]corev1.Toleration `json:"pools,omitempty"`
+}
+
+// WebhookConfig describes how the API server reaches the webhook. When Register is set,
+// the controller creates and updates both webhook configurations itself. Their object
+// selectors never select pods labeled app.kubernetes.io/name SelfName, so that replicas of
+// the controller are admitted while none serves; an empty SelfName selects them too.
+type WebhookConfig struct {
+	Register                       bool                                      `json:"register"`
+	Namespace                      string                                    `json:"namespace,omitempty"`
+	ServiceName                    string                                    `json:"serviceName,omitempty"`
+	ServicePort                    int32                                     `json:"servicePort,omitempty"`
+	MutatingWebhookConfiguration   string                                    `json:"mutatingWebhookConfiguration,omitempty"`
+	ValidatingWebhookConfiguration string                                    `json:"validatingWebhookConfiguration,omitempty"`
+	FailurePolicy                  admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	TimeoutSeconds                 int32                                     `json:"timeoutSeconds,omitempty"`
+	NamespaceSelector              *metav1.LabelSelector                     `json:"namespaceSelector,omitempty"`
+	ObjectSelector                 *metav1.LabelSelector                     `json:"objectSelector,omitempty"`
+	SelfName                       string                                    `json:"selfName,omitempty"`
+}
+
+// CertificatesConfig controls the self-managed serving certificates of the webhook.
+// When SelfManaged is set, a CA and serving certificate are kept in Secret SecretName,
+// the CA bundle is patched into both webhook configurations, and both certificates are
+// renewed RenewBefore their expiry.
+type CertificatesConfig struct {
+	SelfManaged  bool            `json:"selfManaged"`
+	SecretName   string          `json:"secretName,omitempty"`
+	CAValidity   metav1.Duration `json:"caValidity,omitempty"`
+	CertValidity metav1.Duration `json:"certValidity,omitempty"`
+	RenewBefore  metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+// LeaderElectionConfig guards the node tainting controller with a Lease, so that only one
+// replica changes nodes. Every replica serves admission requests regardless.
+type LeaderElectionConfig struct {
+	Enabled       bool            `json:"enabled"`
+	Namespace     string          `json:"namespace,omitempty"`
+	LeaseName     string          `json:"leaseName,omitempty"`
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// DelegationConfig decides when a node whose heartbeat is delegated by the pool coordinator
+// gets tainted unschedulable. By default a node is delegated after Threshold renewals of its
+// lease carrying the delegate-heartbeat annotation. When Window is set, the node is instead
+// delegated once its heartbeats have been delegated without interruption for Window.
+type DelegationConfig struct {
+	Threshold int             `json:"threshold,omitempty"`
+	Window    metav1.Duration `json:"window,omitempty"`
+}
+
+// LivenessConfig decides when a node is dead: its lease was not seen renewed for
+// LeaseDurationSeconds times LeaseDurationMultiplier.
+type LivenessConfig struct {
+	LeaseDurationMultiplier float64 `json:"leaseDurationMultiplier,omitempty"`
+}
+
+// QuorumConfig is what a nodepool must keep for pods to be evicted from a dead node of it.
+// It can be overridden per pool by annotations on the NodePool or on nodes of the pool.
+type QuorumConfig struct {
+	MinNodes      int     `json:"minNodes"`
+	MinAliveRatio float64 `json:"minAliveRatio"`
+	MinAliveNodes int     `json:"minAliveNodes"`
+}
+
+// PartitionConfig turns the share of a pool's nodes whose heartbeat is delegated into a
+// pool state: Partitioned from PartitionedRatio on, Degraded from DegradedRatio on, Connected
+// below. A pool switches state only once the new state held for Hysteresis. When SetAutonomy
+// is set, nodes of a Partitioned pool are put in autonomy until the pool is Connected again.
+type PartitionConfig struct {
+	DegradedRatio    float64         `json:"degradedRatio"`
+	PartitionedRatio float64         `json:"partitionedRatio"`
+	Hysteresis       metav1.Duration `json:"hysteresis,omitempty"`
+	SetAutonomy      bool            `json:"setAutonomy"`
+}
+
+// ThrottleConfig limits how fast deletions validated for an identity get approved in each
+// pool, as the node lifecycle controller does per zone. Deletions beyond EvictionsPerMinute,
+// or PartialDisruptionEvictionsPerMinute once fewer than PartialDisruptionAliveRatio of the
+// pool's nodes are alive, are denied with a retryable reason. A rate of 0 approves none.
+// Burst applies at the primary rate only, a partially disrupted pool gets a burst of 1.
+// Every webhook replica keeps its own limits.
+type ThrottleConfig struct {
+	Enabled                             bool    `json:"enabled"`
+	EvictionsPerMinute                  float64 `json:"evictionsPerMinute"`
+	PartialDisruptionEvictionsPerMinute float64 `json:"partialDisruptionEvictionsPerMinute"`
+	PartialDisruptionAliveRatio         float64 `json:"partialDisruptionAliveRatio"`
+	Burst                               int     `json:"burst,omitempty"`
+}
+
+type EvictionPolicy string
+
+const (
+	// deletions of protected pods are fully validated
+	EvictionPolicyValidate EvictionPolicy = "Validate"
+	// deletions are always allowed
+	EvictionPolicyAllow EvictionPolicy = "Allow"
+	// deletions of protected pods are always denied
+	EvictionPolicyDeny EvictionPolicy = "Deny"
+)
+
+// IdentityConfig maps the users, groups and service accounts ("namespace/name") deleting
+// pods to the policy their deletions get. Names match exactly; the first matching entry
+// wins, deletions by identities matching none are allowed.
+type IdentityConfig struct {
+	Users           []string       `json:"users,omitempty"`
+	Groups          []string       `json:"groups,omitempty"`
+	ServiceAccounts []string       `json:"serviceAccounts,omitempty"`
+	Policy          EvictionPolicy `json:"policy"`
+}
+
+// podNamespace returns the namespace the controller runs in
+func podNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	return "kube-system"
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	return &Config{
+		Webhook: WebhookConfig{
+			Namespace:                      podNamespace(),
+			ServiceName:                    "pool-coordinator-webhook",
+			ServicePort:                    443,
+			MutatingWebhookConfiguration:   "pool-coordinator",
+			ValidatingWebhookConfiguration: "pool-coordinator",
+			FailurePolicy:                  admissionregistrationv1.Fail,
+			TimeoutSeconds:                 10,
+			SelfName:                       "pool-coordinator",
+		},
+		Certificates: CertificatesConfig{
+			SecretName:   "pool-coordinator-webhook-tls",
+			CAValidity:   metav1.Duration{Duration: 10 * 365 * 24 * time.Hour},
+			CertValidity: metav1.Duration{Duration: 365 * 24 * time.Hour},
+			RenewBefore:  metav1.Duration{Duration: 30 * 24 * time.Hour},
+		},
+		LeaderElection: LeaderElectionConfig{
+			Enabled:       true,
+			Namespace:     podNamespace(),
+			LeaseName:     "pool-coordinator-controller",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+		Delegation: DelegationConfig{
+			Threshold: constant.LeaseDelegationThreshold,
+		},
+		Liveness: LivenessConfig{
+			LeaseDurationMultiplier: 1,
+		},
+		Identities: []IdentityConfig{
+			{
+				ServiceAccounts: []string{"kube-system/node-controller"},
+				Policy:          EvictionPolicyValidate,
+			},
+		},
+		Quorum: QuorumConfig{
+			MinNodes:      constant.PoolMinNodes,
+			MinAliveRatio: constant.PoolAliveNodeRatio,
+		},
+		Partition: PartitionConfig{
+			DegradedRatio:    0.2,
+			PartitionedRatio: 0.6,
+			Hysteresis:       metav1.Duration{Duration: time.Minute},
+		},
+		Throttle: ThrottleConfig{
+			Enabled:                             true,
+			EvictionsPerMinute:                  60,
+			PartialDisruptionEvictionsPerMinute: 6,
+			PartialDisruptionAliveRatio:         0.55,
+			Burst:                               10,
+		},
+		Tolerations: TolerationsConfig{
+			Default: []corev1.Toleration{
+				{Key: corev1.TaintNodeUnreachable,
+					Operator: corev1.TolerationOpExists,
+					Effect:   corev1.TaintEffectNoExecute},
+				{Key: corev1.TaintNodeNotReady,
+					Operator: corev1.TolerationOpExists,
+					Effect:   corev1.TaintEffectNoExecute},
+			},
+		},
+	}
+}
+
+// Load reads configuration from file fn, falling back to defaults if it does not exist
+func Load(fn string) (*Config, error) {
+	data, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		klog.Infof("config file %s not found, using defaults", fn)
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %v", fn, err)
+	}
+	return cfg, nil
+}
+
+// Parse reads configuration from data, as found in the config file, on top of defaults
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("could not parse config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for values the webhook cannot act on
+func (c *Config) Validate() error {
+	if err := validateTolerations("default", c.Tolerations.Default); err != nil {
+		return err
+	}
+	for ns, tols := range c.Tolerations.Namespaces {
+		if err := validateTolerations("namespace "+ns, tols); err != nil {
+			return err
+		}
+	}
+	for pool, tols := range c.Tolerations.Pools {
+		if err := validateTolerations("pool "+pool, tols); err != nil {
+			return err
+		}
+	}
+	switch c.Webhook.FailurePolicy {
+	case admissionregistrationv1.Fail, admissionregistrationv1.Ignore:
+	default:
+		return fmt.Errorf("webhook: unknown failurePolicy %q", c.Webhook.FailurePolicy)
+	}
+	if c.Webhook.TimeoutSeconds < 1 || c.Webhook.TimeoutSeconds > 30 {
+		return fmt.Errorf("webhook: timeoutSeconds must be between 1 and 30")
+	}
+	if c.LeaderElection.Enabled {
+		le := c.LeaderElection
+		if le.LeaseDuration.Duration <= le.RenewDeadline.Duration || le.RenewDeadline.Duration <= le.RetryPeriod.Duration {
+			return fmt.Errorf("leaderElection: leaseDuration > renewDeadline > retryPeriod is required")
+		}
+	}
+	if c.Delegation.Threshold < 1 {
+		return fmt.Errorf("delegation: threshold must be at least 1")
+	}
+	if c.Delegation.Window.Duration < 0 {
+		return fmt.Errorf("delegation: window must not be negative")
+	}
+	if c.Liveness.LeaseDurationMultiplier <= 0 {
+		return fmt.Errorf("liveness: leaseDurationMultiplier must be positive")
+	}
+	for i, id := range c.Identities {
+		if err := id.validate(); err != nil {
+			return fmt.Errorf("identities[%d]: %v", i, err)
+		}
+	}
+	if err := c.Quorum.validate(); err != nil {
+		return fmt.Errorf("quorum: %v", err)
+	}
+	if p := c.Partition; p.DegradedRatio <= 0 || p.DegradedRatio > p.PartitionedRatio || p.PartitionedRatio > 1 {
+		return fmt.Errorf("partition: 0 < degradedRatio <= partitionedRatio <= 1 is required")
+	}
+	if c.Partition.Hysteresis.Duration < 0 {
+		return fmt.Errorf("partition: hysteresis must not be negative")
+	}
+	if err := c.Throttle.validate(); err != nil {
+		return fmt.Errorf("throttle: %v", err)
+	}
+	if c.Certificates.SelfManaged {
+		if c.Certificates.RenewBefore.Duration >= c.Certificates.CertValidity.Duration ||
+			c.Certificates.RenewBefore.Duration >= c.Certificates.CAValidity.Duration {
+			return fmt.Errorf("certificates: renewBefore must be shorter than certValidity and caValidity")
+		}
+	}
+	return nil
+}
+
+func validateTolerations(scope string, tols []corev1.Toleration) error {
+	for _, t := range tols {
+		switch t.Operator {
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return fmt.Errorf("tolerations %s: key %q with operator Exists must not have a value", scope, t.Key)
+			}
+		case corev1.TolerationOpEqual, "":
+		default:
+			return fmt.Errorf("tolerations %s: key %q has unknown operator %q", scope, t.Key, t.Operator)
+		}
+		if t.TolerationSeconds != nil {
+			if t.Effect != corev1.TaintEffectNoExecute {
+				return fmt.Errorf("tolerations %s: key %q sets tolerationSeconds without NoExecute effect", scope, t.Key)
+			}
+			if *t.TolerationSeconds < 0 {
+				return fmt.Errorf("tolerations %s: key %q has negative tolerationSeconds", scope, t.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// IsDryRun tells whether denials in given namespace and pool should only be reported
+func (c *DryRunConfig) IsDryRun(namespace, pool string) bool {
+	if v, ok := c.Namespaces[namespace]; ok {
+		return v
+	}
+	if v, ok := c.Pools[pool]; ok && pool != "" {
+		return v
+	}
+	return c.Enabled
+}
+
+// For returns the tolerations to inject into a pod of given namespace and pool
+func (c *TolerationsConfig) For(namespace, pool string) []corev1.Toleration {
+	if tols, ok := c.Namespaces[namespace]; ok {
+		return tols
+	}
+	if tols, ok := c.Pools[pool]; ok && pool != "" {
+		return tols
+	}
+	return c.Default
+}
+
+func (t ThrottleConfig) validate() error {
+	if t.EvictionsPerMinute < 0 || t.PartialDisruptionEvictionsPerMinute < 0 {
+		return fmt.Errorf("evictionsPerMinute and partialDisruptionEvictionsPerMinute must not be negative")
+	}
+	if t.PartialDisruptionAliveRatio < 0 || t.PartialDisruptionAliveRatio > 1 {
+		return fmt.Errorf("partialDisruptionAliveRatio must be between 0 and 1")
+	}
+	if t.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1")
+	}
+	return nil
+}
+
+func (q QuorumConfig) validate() error {
+	if q.MinNodes < 0 || q.MinAliveNodes < 0 {
+		return fmt.Errorf("minNodes and minAliveNodes must not be negative")
+	}
+	if q.MinAliveRatio < 0 || q.MinAliveRatio > 1 {
+		return fmt.Errorf("minAliveRatio must be between 0 and 1")
+	}
+	return nil
+}
+
+// Override returns q with the thresholds set by quorum annotations replaced,
+// annotations with invalid values are ignored
+func (q QuorumConfig) Override(annotations map[string]string) QuorumConfig {
+	o := q
+	if v, ok := annotations[constant.AnnotationKeyMinNodes]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			o.MinNodes = n
+		}
+	}
+	if v, ok := annotations[constant.AnnotationKeyMinAliveRatio]; ok {
+		if r, err := strconv.ParseFloat(v, 64); err == nil {
+			o.MinAliveRatio = r
+		}
+	}
+	if v, ok := annotations[constant.AnnotationKeyMinAliveNodes]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			o.MinAliveNodes = n
+		}
+	}
+	if err := o.validate(); err != nil {
+		klog.Warningf("ignoring quorum annotations %v: %v", annotations, err)
+		return q
+	}
+	return o
+}
+
+func (id IdentityConfig) validate() error {
+	switch id.Policy {
+	case EvictionPolicyValidate, EvictionPolicyAllow, EvictionPolicyDeny:
+	default:
+		return fmt.Errorf("unknown policy %q", id.Policy)
+	}
+	if len(id.Users)+len(id.Groups)+len(id.ServiceAccounts) == 0 {
+		return fmt.Errorf("no users, groups or serviceAccounts")
+	}
+	for _, sa := range id.ServiceAccounts {
+		if parts := strings.Split(sa, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("service account %q is not namespace/name", sa)
+		}
+	}
+	return nil
+}
+
+// matches tells whether a user of given name and groups is one of the identities
+func (id IdentityConfig) matches(username string, groups []string) bool {
+	for _, u := range id.Users {
+		if u == username {
+			return true
+		}
+	}
+	for _, sa := range id.ServiceAccounts {
+		if "system:serviceaccount:"+strings.Replace(sa, "/", ":", 1) == username {
+			return true
+		}
+	}
+	for _, g := range id.Groups {
+		for _, ug := range groups {
+			if g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EvictionPolicyFor returns the policy for deletions by a user of given name and groups
+func (c *Config) EvictionPolicyFor(username string, groups []string) EvictionPolicy {
+	for _, id := range c.Identities {
+		if id.matches(username, groups) {
+			return id.Policy
+		}
+	}
+	return EvictionPolicyAllow
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+)
+
+func TestLoadTolerations(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "config.yaml")
+	data := `
+tolerations:
+  namespaces:
+    video:
+    - key: node.kubernetes.io/unreachable
+      operator: Exists
+      effect: NoExecute
+      tolerationSeconds: 7200
+  pools:
+    kiosk:
+    - key: node.kubernetes.io/unreachable
+      operator: Exists
+      effect: NoExecute
+      tolerationSeconds: 300
+`
+	if err := os.WriteFile(fn, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace string
+		pool      string
+		count     int
+		seconds   int64
+	}{
+		{"video", "kiosk", 1, 7200},
+		{"default", "kiosk", 1, 300},
+		{"default", "factory", 2, -1},
+	}
+	for _, tt := range tests {
+		tols := cfg.Tolerations.For(tt.namespace, tt.pool)
+		if len(tols) != tt.count {
+			t.Errorf("%s/%s: expect %v, but %v returned", tt.namespace, tt.pool, tt.count, len(tols))
+			continue
+		}
+		seconds := int64(-1)
+		if tols[0].TolerationSeconds != nil {
+			seconds = *tols[0].TolerationSeconds
+		}
+		if seconds != tt.seconds {
+			t.Errorf("%s/%s: expect %v, but %v returned", tt.namespace, tt.pool, tt.seconds, seconds)
+		}
+	}
+}
+
+func TestLoadInvalidTolerations(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "config.yaml")
+	data := `
+tolerations:
+  default:
+  - key: node.kubernetes.io/unreachable
+    operator: Exists
+    effect: NoSchedule
+    tolerationSeconds: 300
+`
+	if err := os.WriteFile(fn, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(fn); err == nil {
+		t.Errorf("expect error, but nil returned")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Tolerations.Default) != 2 {
+		t.Errorf("expect %v, but %v returned", 2, len(cfg.Tolerations.Default))
+	}
+}
+
+func TestQuorumOverride(t *testing.T) {
+	q := Default().Quorum
+	o := q.Override(map[string]string{
+		constant.AnnotationKeyMinNodes:      "2",
+		constant.AnnotationKeyMinAliveRatio: "0.5",
+	})
+	if o.MinNodes != 2 || o.MinAliveRatio != 0.5 || o.MinAliveNodes != q.MinAliveNodes {
+		t.Errorf("expect overridden minNodes and minAliveRatio, but %+v returned", o)
+	}
+
+	// out of range values are ignored
+	o = q.Override(map[string]string{constant.AnnotationKeyMinAliveRatio: "2"})
+	if o != q {
+		t.Errorf("expect %+v, but %+v returned", q, o)
+	}
+}
+
+func TestValidateIdentities(t *testing.T) {
+	tests := []struct {
+		identity IdentityConfig
+		valid    bool
+	}{
+		{IdentityConfig{Users: []string{"descheduler"}, Policy: EvictionPolicyDeny}, true},
+		{IdentityConfig{Users: []string{"descheduler"}, Policy: "Maybe"}, false},
+		{IdentityConfig{Policy: EvictionPolicyAllow}, false},
+		{IdentityConfig{ServiceAccounts: []string{"node-controller"}, Policy: EvictionPolicyValidate}, false},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Identities = []IdentityConfig{tt.identity}
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expect valid %v, but %v returned", tt.identity, tt.valid, err)
+		}
+	}
+}
+
+func TestValidatePartition(t *testing.T) {
+	tests := []struct {
+		partition PartitionConfig
+		valid     bool
+	}{
+		{Default().Partition, true},
+		{PartitionConfig{DegradedRatio: 0.5, PartitionedRatio: 0.5}, true},
+		{PartitionConfig{DegradedRatio: 0.7, PartitionedRatio: 0.5}, false},
+		{PartitionConfig{DegradedRatio: 0, PartitionedRatio: 0.5}, false},
+		{PartitionConfig{DegradedRatio: 0.2, PartitionedRatio: 1.5}, false},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Partition = tt.partition
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: expect valid %v, but %v returned", tt.partition, tt.valid, err)
+		}
+	}
+}
@@ -3,20 +3,43 @@ package constant
 const (
 	// nodeutil.AnnotationKeyNodeAutonomy
 	AnnotationKeyNodeAutonomy = "node.beta.openyurt.io/autonomy"
-	LabelKeyNodePool          = "apps.openyurt.io/nodepool"
+	// how long a node stays in autonomy once its heartbeat is lost, "0" means without limit
+	AnnotationKeyNodeAutonomyDuration = "node.openyurt.io/autonomy-duration"
+	// set by the controller, how long a node without heartbeat has left in autonomy
+	AnnotationKeyAutonomyRemaining = "pool-coordinator.openyurt.io/autonomy-remaining"
+	// set by the controller while it keeps a node of a partitioned pool in autonomy, the
+	// autonomy annotation the node had before as a JSON string, null if none
+	AnnotationKeyPreviousAutonomy = "pool-coordinator.openyurt.io/previous-autonomy"
+
+	LabelKeyNodePool = "apps.openyurt.io/nodepool"
+
+	// NodePool spec.type
+	NodePoolTypeEdge  = "Edge"
+	NodePoolTypeCloud = "Cloud"
 
 	// pod can have two provisioning modes: node bonding, or nodepool bonding
 	PodAvailableAnnotation = "pod.beta.openyurt.io/available"
 	PodAvailableNode       = "node"
 	PodAvailablePool       = "pool"
+	// how long a pod stays protected once the heartbeat of its node is lost, takes precedence over the node's
+	PodAutonomyDurationAnnotation = "pod.openyurt.io/autonomy-duration"
 
 	DelegateHeartBeat = "openyurt.io/delegate-heartbeat"
+	// node condition telling whether the heartbeat of the node is delegated by a pool coordinator
+	NodeConditionDelegated = "PoolCoordinatorDelegated"
 
 	// when node cannot reach api-server directly but can be delegated lease, we should taint the node as unschedulable
 	NodeNotSchedulableTaint = "node.openyurt.io/unschedulable"
-	// number of lease intervals passed before we taint/detaint node as unschedulable
+	// default number of delegated lease renewals before we taint node as unschedulable
 	LeaseDelegationThreshold = 4
 
 	// when ready nodes in a pool is below this value, we don't allow pod transition any more
 	PoolAliveNodeRatio = 0.3
+	// when a pool has fewer nodes than this value, we don't allow pod transition at all
+	PoolMinNodes = 3
+
+	// quorum overrides, set on a NodePool or on nodes of the pool
+	AnnotationKeyMinNodes      = "pool-coordinator.openyurt.io/min-nodes"
+	AnnotationKeyMinAliveRatio = "pool-coordinator.openyurt.io/min-alive-ratio"
+	AnnotationKeyMinAliveNodes = "pool-coordinator.openyurt.io/min-alive-nodes"
 )
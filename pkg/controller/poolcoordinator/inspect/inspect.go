@@ -0,0 +1,240 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/lister"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/webhook"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	policylisterv1 "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// State is a snapshot of what pool-coordinator decides on, taken the way the controller
+// and webhook build it from informers
+type State struct {
+	Config     *config.Config
+	Nodes      listerv1.NodeLister
+	Pods       listerv1.PodLister
+	PodIndexer cache.Indexer
+	PDBs       policylisterv1.PodDisruptionBudgetLister
+	Leases     leaselisterv1.LeaseNamespaceLister
+	Liveness   *utils.LivenessTracker
+	Pools      *utils.Pools
+	Health     *utils.PoolHealth
+	// TrustRenewTime takes the renew time of a lease as when it was renewed if the API
+	// server did not record when it wrote the lease, despite the clock skew of nodes
+	TrustRenewTime bool
+}
+
+// LoadConfig reads the pool-coordinator config from key config.yaml of ConfigMap
+// namespace/name. A missing ConfigMap is an error, as answers on other policies than the
+// webhook's would mislead.
+func LoadConfig(client kubernetes.Interface, namespace, name string) (*config.Config, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("configmap %s/%s not found, give the one of the release or -config, or use -defaults", namespace, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data["config.yaml"]
+	if !ok {
+		return nil, fmt.Errorf("configmap %s/%s has no config.yaml", namespace, name)
+	}
+	return config.Parse([]byte(data))
+}
+
+// Load takes a snapshot of the cluster through client and dynamicClient
+func Load(client kubernetes.Interface, dynamicClient dynamic.Interface, cfg *config.Config) *State {
+	stopper := make(chan struct{})
+	defer close(stopper)
+
+	st := &State{Config: cfg}
+	st.Leases = lister.CreateLeaseLister(client, stopper, nil, nil, nil)
+	lister.CreateNodePoolLister(client, dynamicClient, stopper, nil, nil, nil)
+	st.Nodes = lister.CreateNodeLister(client, stopper, nil, nil, nil)
+	st.Pods = lister.CreatePodLister(client, stopper, nil, nil, nil)
+	st.PodIndexer = lister.PodIndexer()
+	st.PDBs = lister.CreatePDBLister(client, stopper, nil, nil, nil)
+	st.Pools = utils.NewPools(lister.NodeIndexer(), lister.NodePoolIndexer())
+	st.Sync()
+	return st
+}
+
+// Sync derives liveness and pool health from the leases and nodes of st. A snapshot sees
+// no renewals, so a lease counts as renewed when the API server last wrote it; without that
+// record, the node's liveness is unknown unless TrustRenewTime is set. The streak of
+// delegated heartbeats is counted by the controller leader, which only publishes it in the
+// delegated condition, so a node counts as delegated here if its current lease is.
+func (st *State) Sync() {
+	st.Liveness = utils.NewLivenessTracker(st.Config.Liveness.LeaseDurationMultiplier)
+	st.Health = utils.NewPoolHealth()
+
+	leases, _ := st.Leases.List(labels.Everything())
+	for _, l := range leases {
+		switch at, ok := utils.ServerRenewTime(l); {
+		case ok:
+			st.Liveness.ObserveRenewal(l, at)
+		case st.TrustRenewTime && l.Spec.RenewTime != nil:
+			st.Liveness.ObserveRenewal(l, l.Spec.RenewTime.Time)
+		default:
+			st.Liveness.Observe(l)
+		}
+	}
+	nodes, _ := st.Nodes.List(labels.Everything())
+	for _, node := range nodes {
+		pool, ok := st.Pools.Pool(node)
+		if !ok {
+			continue
+		}
+		st.Health.Set(node.Name, pool, st.Liveness.Status(node.Name) == utils.LivenessAlive, st.delegated(node.Name))
+	}
+}
+
+// delegated tells whether the current lease of node name is renewed by a pool coordinator
+func (st *State) delegated(name string) bool {
+	l, err := st.Leases.Get(name)
+	return err == nil && l.Annotations[constant.DelegateHeartBeat] == "true"
+}
+
+// tainted tells whether node name carries the unschedulable taint
+func (st *State) tainted(name string) bool {
+	node, err := st.Nodes.Get(name)
+	return err == nil && utils.TaintKeyExists(node.Spec.Taints, constant.NodeNotSchedulableTaint)
+}
+
+// PrintPools prints the node counts and partition state of every pool
+func (st *State) PrintPools(out io.Writer) error {
+	p := st.Config.Partition
+	detector := utils.NewPartitionDetector(p.DegradedRatio, p.PartitionedRatio, p.Hysteresis.Duration)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tNODES\tALIVE\tDELEGATED\tTAINTED\tSTATE")
+	for _, pool := range st.Pools.Pools() {
+		s := st.Health.Stats(pool)
+		tainted := 0
+		for _, name := range st.Pools.Nodes(pool) {
+			if st.tainted(name) {
+				tainted++
+			}
+		}
+		// without history, the state is the one the pool points to right now
+		state, _ := detector.Observe(pool, s, time.Now())
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", pool, s.Nodes, s.Alive, s.Delegated, tainted, state)
+	}
+	return w.Flush()
+}
+
+// PrintNode prints the lease, delegation, autonomy and taints of node name
+func (st *State) PrintNode(out io.Writer, name string) error {
+	node, err := st.Nodes.Get(name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	pool, _ := st.Pools.Pool(node)
+	fmt.Fprintf(w, "Name:\t%s\n", node.Name)
+	fmt.Fprintf(w, "Pool:\t%s\n", valueOr(pool, "<none>"))
+	fmt.Fprintf(w, "Liveness:\t%s\n", st.Liveness.Status(name))
+
+	lease, err := st.Leases.Get(name)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "Lease:\t<none>\n")
+	case lease.Spec.RenewTime == nil:
+		fmt.Fprintf(w, "Lease:\tnever renewed\n")
+	default:
+		holder := ""
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		fmt.Fprintf(w, "Lease age:\t%s\n", time.Since(lease.Spec.RenewTime.Time).Truncate(time.Second))
+		fmt.Fprintf(w, "Lease holder:\t%s\n", valueOr(holder, "<none>"))
+	}
+	fmt.Fprintf(w, "Delegated:\t%v\n", st.delegated(name))
+	for _, c := range node.Status.Conditions {
+		if c.Type == constant.NodeConditionDelegated {
+			fmt.Fprintf(w, "Delegated condition:\t%s since %s, %s\n", c.Status, c.LastTransitionTime.Format(time.RFC3339), c.Reason)
+			// the leader publishes the streak of delegated heartbeats it counts in the message
+			fmt.Fprintf(w, "Delegation:\t%s\n", c.Message)
+		}
+	}
+
+	autonomy := "<none>"
+	if d, ok := st.Pools.AutonomyDuration(node); ok {
+		autonomy = "without limit"
+		if d > 0 {
+			autonomy = d.String()
+		}
+	}
+	fmt.Fprintf(w, "Autonomy:\t%s\n", autonomy)
+	if v, ok := node.Annotations[constant.AnnotationKeyAutonomyRemaining]; ok {
+		fmt.Fprintf(w, "Autonomy remaining:\t%s\n", v)
+	}
+	if _, ok := node.Annotations[constant.AnnotationKeyPreviousAutonomy]; ok {
+		fmt.Fprintf(w, "Autonomy set by:\tpartition of the pool\n")
+	}
+
+	taints := []string{}
+	for _, t := range node.Spec.Taints {
+		taints = append(taints, t.ToString())
+	}
+	sort.Strings(taints)
+	fmt.Fprintf(w, "Taints:\t%s\n", valueOr(strings.Join(taints, ", "), "<none>"))
+	return w.Flush()
+}
+
+// Explain prints whether an eviction of pod namespace/name by user would be allowed right
+// now, and why, as the validating webhook would decide
+func (st *State) Explain(out io.Writer, namespace, name string, user authenticationv1.UserInfo) error {
+	pod, err := st.Pods.Pods(namespace).Get(name)
+	if err != nil {
+		return err
+	}
+
+	// throttling is kept per webhook replica, a snapshot cannot tell
+	cfg := *st.Config
+	cfg.Throttle.Enabled = false
+	webhook.Setup(&cfg, st.Nodes, st.Pods, st.PodIndexer, st.PDBs, st.Liveness, st.Pools, st.Health, &record.FakeRecorder{})
+	allowed, reason, err := webhook.Explain(pod, user)
+	if err != nil {
+		return err
+	}
+
+	verdict := "denied"
+	if allowed {
+		verdict = "allowed"
+	}
+	fmt.Fprintf(out, "eviction of pod %s/%s on node %s by %s would be %s: %s\n",
+		namespace, name, pod.Spec.NodeName, user.Username, verdict, reason)
+	if allowed && st.Config.Throttle.Enabled {
+		fmt.Fprintln(out, "approved evictions are throttled per nodepool, it may still be delayed")
+	}
+	return nil
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
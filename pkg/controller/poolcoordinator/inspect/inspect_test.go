@@ -0,0 +1,185 @@
+package inspect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testState(t *testing.T) *State {
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodeIndexers())
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.PodIndexers())
+	leases := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	duration := int32(40)
+	for i, name := range []string{"node1", "node2", "node3"} {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{constant.LabelKeyNodePool: "pool1"},
+			},
+		}
+		lease := &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: corev1.NamespaceNodeLease},
+			Spec: coordv1.LeaseSpec{
+				LeaseDurationSeconds: &duration,
+				RenewTime:            &metav1.MicroTime{Time: time.Now()},
+			},
+		}
+		switch i {
+		case 0:
+			// dead and tainted
+			lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(-time.Minute)}
+			node.Spec.Taints = []corev1.Taint{{Key: constant.NodeNotSchedulableTaint, Value: "true", Effect: corev1.TaintEffectNoSchedule}}
+		case 1:
+			lease.Annotations = map[string]string{constant.DelegateHeartBeat: "true"}
+			node.Annotations = map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "10m"}
+			node.Status.Conditions = []corev1.NodeCondition{{
+				Type:    constant.NodeConditionDelegated,
+				Status:  corev1.ConditionTrue,
+				Reason:  "HeartbeatDelegated",
+				Message: "node lease is renewed by pool coordinator, 2 of 4 delegated heartbeats since 2026-10-18T10:00:00Z",
+			}}
+		}
+		// written by the API server on its own clock
+		lease.ManagedFields = []metav1.ManagedFieldsEntry{{
+			Manager:   "kubelet",
+			Operation: metav1.ManagedFieldsOperationUpdate,
+			Time:      &metav1.Time{Time: lease.Spec.RenewTime.Time},
+		}}
+		if err := nodes.Add(node); err != nil {
+			t.Fatal(err)
+		}
+		if err := leases.Add(lease); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{constant.PodAvailableAnnotation: constant.PodAvailableNode},
+		},
+		Spec: corev1.PodSpec{NodeName: "node1"},
+	}
+	if err := pods.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &State{
+		Config:     config.Default(),
+		Nodes:      listerv1.NewNodeLister(nodes),
+		Pods:       listerv1.NewPodLister(pods),
+		PodIndexer: pods,
+		Leases:     leaselisterv1.NewLeaseLister(leases).Leases(corev1.NamespaceNodeLease),
+		Pools:      utils.NewPools(nodes, nil),
+	}
+	st.Sync()
+	return st
+}
+
+func TestLoadConfig(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool-coordinator-config", Namespace: "kube-system"},
+		Data:       map[string]string{"config.yaml": "dryRun:\n  enabled: true\n"},
+	})
+	cfg, err := LoadConfig(client, "kube-system", "pool-coordinator-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.DryRun.Enabled {
+		t.Errorf("expect %v, but %v returned", true, cfg.DryRun.Enabled)
+	}
+	if _, err := LoadConfig(client, "kube-system", "missing"); err == nil {
+		t.Errorf("expect error for a missing configmap, but nil returned")
+	}
+}
+
+func TestSyncLiveness(t *testing.T) {
+	st := testState(t)
+	// renewed by the node's clock only
+	for _, name := range []string{"node1", "node2"} {
+		l, err := st.Leases.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.ManagedFields = nil
+	}
+
+	tests := []struct {
+		trust bool
+		node1 utils.Liveness
+		node2 utils.Liveness
+	}{
+		{false, utils.LivenessUnknown, utils.LivenessUnknown},
+		{true, utils.LivenessDead, utils.LivenessAlive},
+	}
+	for _, tt := range tests {
+		st.TrustRenewTime = tt.trust
+		st.Sync()
+		if got := st.Liveness.Status("node1"); got != tt.node1 {
+			t.Errorf("trust %v: expect %v, but %v returned", tt.trust, tt.node1, got)
+		}
+		if got := st.Liveness.Status("node2"); got != tt.node2 {
+			t.Errorf("trust %v: expect %v, but %v returned", tt.trust, tt.node2, got)
+		}
+		if got := st.Liveness.Status("node3"); got != utils.LivenessAlive {
+			t.Errorf("trust %v: expect %v, but %v returned", tt.trust, utils.LivenessAlive, got)
+		}
+	}
+}
+
+func TestPrintPools(t *testing.T) {
+	st := testState(t)
+	out := &bytes.Buffer{}
+	if err := st.PrintPools(out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expect header and one pool, but %q returned", out.String())
+	}
+	if got := strings.Fields(lines[1]); strings.Join(got, " ") != "pool1 3 2 1 1 Degraded" {
+		t.Errorf("expect %q, but %q returned", "pool1 3 2 1 1 Degraded", strings.Join(got, " "))
+	}
+}
+
+func TestPrintNode(t *testing.T) {
+	st := testState(t)
+	out := &bytes.Buffer{}
+	if err := st.PrintNode(out, "node2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Pool:", "pool1", "Alive", "Delegated:", "true", "2 of 4 delegated heartbeats since", "Autonomy:", "10m0s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expect %q in output, but %q returned", want, out.String())
+		}
+	}
+	if err := st.PrintNode(out, "missing"); err == nil {
+		t.Errorf("expect error for a missing node, but nil returned")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	st := testState(t)
+	out := &bytes.Buffer{}
+	user := authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"}
+	if err := st.Explain(out, "default", "web", user); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "would be denied") {
+		t.Errorf("expect a denial, but %q returned", out.String())
+	}
+}
@@ -0,0 +1,77 @@
+package poolcoordinator
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// isLeader tells whether this replica may change nodes
+func (nc *Controller) isLeader() bool {
+	return atomic.LoadInt32(&nc.leading) == 1
+}
+
+// runLeaderElection campaigns for the controller lease until stopper is closed. Losing the
+// lease only stops node changes, informers and the webhook keep running, so the replica
+// campaigns again right away.
+func (nc *Controller) runLeaderElection(stopper <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopper
+		cancel()
+	}()
+
+	le := nc.cfg.LeaderElection
+	if !le.Enabled {
+		atomic.StoreInt32(&nc.leading, 1)
+		nc.startLeading(ctx)
+		return
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Error(err)
+	}
+	id = id + "_" + string(uuid.NewUUID())
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: le.Namespace,
+			Name:      le.LeaseName,
+		},
+		Client: nc.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   le.LeaseDuration.Duration,
+			RenewDeadline:   le.RenewDeadline.Duration,
+			RetryPeriod:     le.RetryPeriod.Duration,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					klog.Infof("%s started leading", id)
+					atomic.StoreInt32(&nc.leading, 1)
+					nc.startLeading(ctx)
+				},
+				OnStoppedLeading: func() {
+					klog.Infof("%s stopped leading", id)
+					atomic.StoreInt32(&nc.leading, 0)
+				},
+				OnNewLeader: func(identity string) {
+					klog.Infof("new leader elected: %s", identity)
+				},
+			},
+		})
+	}
+}
@@ -3,12 +3,18 @@ package lister
 import (
 	"time"
 
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
 	listerv1 "k8s.io/client-go/listers/core/v1"
+	policylisterv1 "k8s.io/client-go/listers/policy/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -17,18 +23,23 @@ const (
 
 var (
 	factory informers.SharedInformerFactory
+	// NodePools are custom resources without generated clients, watched through the dynamic client
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+
+	NodePoolResource = schema.GroupVersionResource{Group: "apps.openyurt.io", Version: "v1beta1", Resource: "nodepools"}
 )
 
 type ACallback func(interface{})
 type UCallback func(interface{}, interface{})
 
-func CreateNodeLister(client *kubernetes.Clientset, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) listerv1.NodeLister {
+func CreateNodeLister(client kubernetes.Interface, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) listerv1.NodeLister {
 	if factory == nil {
 		factory = informers.NewSharedInformerFactory(client, resyncInt)
 	}
 	nodeInformer := factory.Core().V1().Nodes()
 	nodeLister := nodeInformer.Lister()
 	nInformer := nodeInformer.Informer()
+	addIndexers(nInformer, utils.NodeIndexers())
 	nInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    afunc,
 		UpdateFunc: ufunc,
@@ -39,24 +50,41 @@ func CreateNodeLister(client *kubernetes.Clientset, stopper chan (struct{}), afu
 	return nodeLister
 }
 
-func CreatePodLister(client *kubernetes.Clientset, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) listerv1.PodLister {
+func CreatePodLister(client kubernetes.Interface, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) listerv1.PodLister {
 	if factory == nil {
 		factory = informers.NewSharedInformerFactory(client, resyncInt)
 	}
 	podInformer := factory.Core().V1().Pods()
 	podLister := podInformer.Lister()
 	pInformer := podInformer.Informer()
+	addIndexers(pInformer, utils.PodIndexers())
 	pInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    nil,
-		UpdateFunc: nil,
-		DeleteFunc: nil,
+		AddFunc:    afunc,
+		UpdateFunc: ufunc,
+		DeleteFunc: dfunc,
 	})
 	factory.Start(stopper)
 	factory.WaitForCacheSync(stopper)
 	return podLister
 }
 
-func CreateLeaseLister(client *kubernetes.Clientset, stopper chan (struct{}), acb ACallback, ucb UCallback, dcb ACallback) leaselisterv1.LeaseNamespaceLister {
+func CreatePDBLister(client kubernetes.Interface, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) policylisterv1.PodDisruptionBudgetLister {
+	if factory == nil {
+		factory = informers.NewSharedInformerFactory(client, resyncInt)
+	}
+	pdbInformer := factory.Policy().V1().PodDisruptionBudgets()
+	pdbLister := pdbInformer.Lister()
+	pdbInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    afunc,
+		UpdateFunc: ufunc,
+		DeleteFunc: dfunc,
+	})
+	factory.Start(stopper)
+	factory.WaitForCacheSync(stopper)
+	return pdbLister
+}
+
+func CreateLeaseLister(client kubernetes.Interface, stopper chan (struct{}), acb ACallback, ucb UCallback, dcb ACallback) leaselisterv1.LeaseNamespaceLister {
 	if factory == nil {
 		factory = informers.NewSharedInformerFactory(client, resyncInt)
 	}
@@ -72,3 +100,73 @@ func CreateLeaseLister(client *kubernetes.Clientset, stopper chan (struct{}), ac
 	factory.WaitForCacheSync(stopper)
 	return leaseLister
 }
+
+// CreateNodePoolLister returns a lister of NodePools, or nil if the NodePool CRD is not installed
+func CreateNodePoolLister(client kubernetes.Interface, dynamicClient dynamic.Interface, stopper chan (struct{}), afunc ACallback, ufunc UCallback, dfunc ACallback) cache.GenericLister {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(NodePoolResource.GroupVersion().String())
+	if err != nil {
+		klog.Warningf("nodepools not served, NodePool annotations are ignored: %v", err)
+		return nil
+	}
+	served := false
+	for _, r := range resources.APIResources {
+		if r.Name == NodePoolResource.Resource {
+			served = true
+		}
+	}
+	if !served {
+		klog.Warningf("nodepools not served, NodePool annotations are ignored")
+		return nil
+	}
+
+	if dynamicFactory == nil {
+		dynamicFactory = dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, resyncInt)
+	}
+	nodePoolInformer := dynamicFactory.ForResource(NodePoolResource)
+	nodePoolLister := nodePoolInformer.Lister()
+	npInformer := nodePoolInformer.Informer()
+	addIndexers(npInformer, utils.NodePoolIndexers())
+	npInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    afunc,
+		UpdateFunc: ufunc,
+		DeleteFunc: dfunc,
+	})
+	dynamicFactory.Start(stopper)
+	dynamicFactory.WaitForCacheSync(stopper)
+	return nodePoolLister
+}
+
+// NodeIndexer returns the indexer of the node informer, indexed by utils.NodeIndexers
+func NodeIndexer() cache.Indexer {
+	return factory.Core().V1().Nodes().Informer().GetIndexer()
+}
+
+// PodIndexer returns the indexer of the pod informer, indexed by utils.PodIndexers
+func PodIndexer() cache.Indexer {
+	return factory.Core().V1().Pods().Informer().GetIndexer()
+}
+
+// NodePoolIndexer returns the indexer of the NodePool informer, or nil if NodePools are not served
+func NodePoolIndexer() cache.Indexer {
+	if dynamicFactory == nil {
+		return nil
+	}
+	return dynamicFactory.ForResource(NodePoolResource).Informer().GetIndexer()
+}
+
+// addIndexers adds indexers not added yet, the shared informer may be requested more than once
+func addIndexers(informer cache.SharedIndexInformer, indexers cache.Indexers) {
+	missing := cache.Indexers{}
+	existing := informer.GetIndexer().GetIndexers()
+	for name, f := range indexers {
+		if _, ok := existing[name]; !ok {
+			missing[name] = f
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if err := informer.AddIndexers(missing); err != nil {
+		klog.Error(err)
+	}
+}
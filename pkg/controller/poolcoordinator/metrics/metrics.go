@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "pool_coordinator"
+)
+
+var (
+	// DryRunDenials counts deletions that would have been denied if dry-run mode were off
+	DryRunDenials = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "dryrun_denials_total",
+			Help:      "Number of pod deletions the validating webhook would have denied in dry-run mode.",
+		},
+		[]string{"reason"},
+	)
+
+	// AdmissionDecisions counts admission responses by webhook, outcome and reason
+	AdmissionDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "decisions_total",
+			Help:      "Number of admission decisions by webhook (validate/mutate), outcome and reason.",
+		},
+		[]string{"webhook", "outcome", "reason"},
+	)
+
+	// AdmissionDuration observes how long it takes to answer an admission request
+	AdmissionDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of admission requests by webhook (validate/mutate).",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+		[]string{"webhook"},
+	)
+
+	// CertificateReloads counts reloads of the serving certificate from disk
+	CertificateReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhook",
+			Name:      "certificate_reloads_total",
+			Help:      "Number of serving certificate reloads by result.",
+		},
+		[]string{"result"},
+	)
+
+	// TaintOperations counts node taint changes made by the controller
+	TaintOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "controller",
+			Name:      "taint_operations_total",
+			Help:      "Number of unschedulable taint operations by operation (taint/untaint) and result.",
+		},
+		[]string{"operation", "result"},
+	)
+)
+
+// PoolStats is a snapshot of node health in a nodepool
+type PoolStats struct {
+	Pool      string
+	Nodes     int
+	Alive     int
+	Delegated int
+	Tainted   int
+	// Connected, Degraded or Partitioned
+	State string
+}
+
+var (
+	poolNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pool", "nodes"),
+		"Number of nodes in a nodepool.",
+		[]string{"pool"}, nil,
+	)
+	poolAliveNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pool", "alive_nodes"),
+		"Number of nodes in a nodepool with a live lease.",
+		[]string{"pool"}, nil,
+	)
+	poolDelegatedNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pool", "delegated_nodes"),
+		"Number of nodes in a nodepool whose heartbeat is delegated.",
+		[]string{"pool"}, nil,
+	)
+	poolTaintedNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pool", "tainted_nodes"),
+		"Number of nodes in a nodepool tainted as unschedulable.",
+		[]string{"pool"}, nil,
+	)
+	poolStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "pool", "state"),
+		"Partition state of a nodepool, 1 for the current state.",
+		[]string{"pool", "state"}, nil,
+	)
+)
+
+// poolCollector collects per-pool gauges on every scrape
+type poolCollector struct {
+	stats func() []PoolStats
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolNodesDesc
+	ch <- poolAliveNodesDesc
+	ch <- poolDelegatedNodesDesc
+	ch <- poolTaintedNodesDesc
+	ch <- poolStateDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.stats() {
+		ch <- prometheus.MustNewConstMetric(poolNodesDesc, prometheus.GaugeValue, float64(s.Nodes), s.Pool)
+		ch <- prometheus.MustNewConstMetric(poolAliveNodesDesc, prometheus.GaugeValue, float64(s.Alive), s.Pool)
+		ch <- prometheus.MustNewConstMetric(poolDelegatedNodesDesc, prometheus.GaugeValue, float64(s.Delegated), s.Pool)
+		ch <- prometheus.MustNewConstMetric(poolTaintedNodesDesc, prometheus.GaugeValue, float64(s.Tainted), s.Pool)
+		ch <- prometheus.MustNewConstMetric(poolStateDesc, prometheus.GaugeValue, 1, s.Pool, s.State)
+	}
+}
+
+// RegisterPoolCollector exports per-pool gauges computed by stats at scrape time
+func RegisterPoolCollector(stats func() []PoolStats) {
+	prometheus.MustRegister(&poolCollector{stats: stats})
+}
+
+func init() {
+	prometheus.MustRegister(DryRunDenials)
+	prometheus.MustRegister(AdmissionDecisions)
+	prometheus.MustRegister(AdmissionDuration)
+	prometheus.MustRegister(CertificateReloads)
+	prometheus.MustRegister(TaintOperations)
+}
@@ -0,0 +1,121 @@
+package poolcoordinator
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how often pool states are derived from pool health
+	partitionDetectInterval = 5 * time.Second
+)
+
+// detectPartitions updates the state of every pool. Every replica keeps the states, so a new
+// leader starts with warm state, only the leader switches autonomy of nodes.
+func (nc *Controller) detectPartitions() {
+	pools := nc.health.Pools()
+	nc.partitions.Retain(pools)
+	for _, pool := range pools {
+		state, changed := nc.partitions.Observe(pool, nc.health.Stats(pool), time.Now())
+		if changed {
+			klog.Infof("nodepool %s is %s", pool, state)
+		}
+		if !nc.isLeader() {
+			continue
+		}
+		switch state {
+		case utils.PoolPartitioned:
+			if nc.cfg.Partition.SetAutonomy {
+				nc.setPoolAutonomy(pool)
+			}
+		case utils.PoolConnected:
+			nc.restorePoolAutonomy(pool)
+		}
+	}
+}
+
+// setPoolAutonomy puts the nodes of partitioned pool in autonomy, saving their previous
+// autonomy annotation. Nodes already switched are left alone.
+func (nc *Controller) setPoolAutonomy(pool string) {
+	if np := nc.pools.NodePool(pool); np != nil && np.IsCloud() {
+		return
+	}
+	for _, name := range nc.pools.Nodes(pool) {
+		node, err := nc.nodeLister.Get(name)
+		if err != nil {
+			continue
+		}
+		if _, ok := node.Annotations[constant.AnnotationKeyPreviousAutonomy]; ok {
+			continue
+		}
+		previous := encodePreviousAutonomy(node.Annotations, constant.AnnotationKeyNodeAutonomy)
+		autonomy := "true"
+		err = nc.patchNodeAnnotations(name, node.ResourceVersion, map[string]*string{
+			constant.AnnotationKeyNodeAutonomy:     &autonomy,
+			constant.AnnotationKeyPreviousAutonomy: &previous,
+		})
+		if err != nil {
+			klog.Warningf("could not put node %s of partitioned nodepool %s in autonomy: %v", name, pool, err)
+			continue
+		}
+		klog.Infof("put node %s of partitioned nodepool %s in autonomy", name, pool)
+	}
+}
+
+// restorePoolAutonomy gives the nodes of reconnected pool back the autonomy annotation they
+// had before the pool was partitioned. Nodes whose autonomy was changed meanwhile keep it.
+func (nc *Controller) restorePoolAutonomy(pool string) {
+	for _, name := range nc.pools.Nodes(pool) {
+		node, err := nc.nodeLister.Get(name)
+		if err != nil {
+			continue
+		}
+		saved, ok := node.Annotations[constant.AnnotationKeyPreviousAutonomy]
+		if !ok {
+			continue
+		}
+		annotations := map[string]*string{constant.AnnotationKeyPreviousAutonomy: nil}
+		restore := node.Annotations[constant.AnnotationKeyNodeAutonomy] == "true"
+		if restore {
+			annotations[constant.AnnotationKeyNodeAutonomy] = decodePreviousAutonomy(saved)
+		}
+		err = nc.patchNodeAnnotations(name, node.ResourceVersion, annotations)
+		if err != nil {
+			klog.Warningf("could not restore autonomy of node %s of nodepool %s: %v", name, pool, err)
+			continue
+		}
+		if restore {
+			klog.Infof("restored autonomy of node %s of reconnected nodepool %s", name, pool)
+		} else {
+			klog.Infof("kept autonomy of node %s of reconnected nodepool %s, changed while partitioned", name, pool)
+		}
+	}
+}
+
+// encodePreviousAutonomy records annotation key of annotations, telling an absent one from
+// an empty one
+func encodePreviousAutonomy(annotations map[string]string, key string) string {
+	var v *string
+	if value, ok := annotations[key]; ok {
+		v = &value
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// decodePreviousAutonomy returns the annotation value recorded in saved, nil if it was absent
+func decodePreviousAutonomy(saved string) *string {
+	var v *string
+	if err := json.Unmarshal([]byte(saved), &v); err != nil {
+		// recorded as the plain value, empty if absent
+		if saved == "" {
+			return nil
+		}
+		return &saved
+	}
+	return v
+}
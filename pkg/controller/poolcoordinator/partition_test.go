@@ -0,0 +1,113 @@
+package poolcoordinator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func partitionNode(name string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{constant.LabelKeyNodePool: "pool1"},
+			Annotations: annotations,
+		},
+	}
+}
+
+func nodeAnnotations(t *testing.T, nc *Controller, name string) map[string]string {
+	node, err := nc.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node.Annotations
+}
+
+func TestPartitionSetsAutonomy(t *testing.T) {
+	nc := newTestController(t,
+		partitionNode("node1", nil),
+		partitionNode("node2", map[string]string{constant.AnnotationKeyNodeAutonomy: "false"}),
+		partitionNode("node3", map[string]string{constant.AnnotationKeyNodeAutonomy: ""}),
+	)
+	defer nc.queue.ShutDown()
+	nc.cfg = config.Default()
+	nc.cfg.Partition.SetAutonomy = true
+	nc.leading = 1
+	nc.health.Set("node1", "pool1", true, true)
+	nc.health.Set("node2", "pool1", true, true)
+	nc.health.Set("node3", "pool1", true, true)
+
+	nc.detectPartitions()
+	for name, previous := range map[string]string{"node1": "null", "node2": `"false"`, "node3": `""`} {
+		a := nodeAnnotations(t, nc, name)
+		if a[constant.AnnotationKeyNodeAutonomy] != "true" {
+			t.Errorf("%s: expect autonomy %v, but %q returned", name, true, a[constant.AnnotationKeyNodeAutonomy])
+		}
+		if v, ok := a[constant.AnnotationKeyPreviousAutonomy]; !ok || v != previous {
+			t.Errorf("%s: expect previous autonomy %q, but %q returned", name, previous, v)
+		}
+	}
+}
+
+func TestReconnectRestoresAutonomy(t *testing.T) {
+	nc := newTestController(t,
+		partitionNode("node1", map[string]string{
+			constant.AnnotationKeyNodeAutonomy:     "true",
+			constant.AnnotationKeyPreviousAutonomy: "null",
+		}),
+		partitionNode("node2", map[string]string{
+			constant.AnnotationKeyNodeAutonomy:     "true",
+			constant.AnnotationKeyPreviousAutonomy: `"false"`,
+		}),
+		partitionNode("node3", map[string]string{constant.AnnotationKeyNodeAutonomy: "true"}),
+		partitionNode("node4", map[string]string{
+			constant.AnnotationKeyNodeAutonomy:     "true",
+			constant.AnnotationKeyPreviousAutonomy: `""`,
+		}),
+		// changed by an operator while partitioned
+		partitionNode("node5", map[string]string{
+			constant.AnnotationKeyNodeAutonomy:     "false",
+			constant.AnnotationKeyPreviousAutonomy: "null",
+		}),
+		// recorded as the plain value
+		partitionNode("node6", map[string]string{
+			constant.AnnotationKeyNodeAutonomy:     "true",
+			constant.AnnotationKeyPreviousAutonomy: "false",
+		}),
+	)
+	defer nc.queue.ShutDown()
+	nc.cfg = config.Default()
+	nc.leading = 1
+	for _, name := range []string{"node1", "node2", "node3", "node4", "node5", "node6"} {
+		nc.health.Set(name, "pool1", true, false)
+	}
+
+	nc.detectPartitions()
+	tests := []struct {
+		node     string
+		autonomy string
+		exists   bool
+	}{
+		{"node1", "", false},
+		{"node2", "false", true},
+		// set by the user, not by the controller
+		{"node3", "true", true},
+		{"node4", "", true},
+		{"node5", "false", true},
+		{"node6", "false", true},
+	}
+	for _, tt := range tests {
+		a := nodeAnnotations(t, nc, tt.node)
+		if v, ok := a[constant.AnnotationKeyNodeAutonomy]; ok != tt.exists || v != tt.autonomy {
+			t.Errorf("%s: expect autonomy %q, but %q returned", tt.node, tt.autonomy, v)
+		}
+		if _, ok := a[constant.AnnotationKeyPreviousAutonomy]; ok {
+			t.Errorf("%s: expect previous autonomy removed, but it is kept", tt.node)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package poolcoordinator
+
+import (
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// runPoolHealth keeps pool health up to date until stopCh is closed. Nodes are updated on
+// lease, node and nodepool events, and again once their liveness or delegation is due to
+// change without one.
+func (nc *Controller) runPoolHealth(stopCh <-chan struct{}) {
+	go func() {
+		for nc.processNextHealthItem() {
+		}
+	}()
+	<-stopCh
+	nc.healthQueue.ShutDown()
+}
+
+func (nc *Controller) processNextHealthItem() bool {
+	key, quit := nc.healthQueue.Get()
+	if quit {
+		return false
+	}
+	defer nc.healthQueue.Done(key)
+
+	nc.updateNodeHealth(key.(string))
+	return true
+}
+
+// updateNodeHealth records the pool, liveness and delegation of node name in pool health,
+// and schedules it again when its lease times out or its delegation window passes
+func (nc *Controller) updateNodeHealth(name string) {
+	node, err := nc.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		nc.health.Delete(name)
+		return
+	}
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	pool, ok := nc.pools.Pool(node)
+	if !ok {
+		nc.health.Delete(name)
+		return
+	}
+	delegated, next := ldc.Delegated(name)
+	nc.health.Set(name, pool, nc.liveness.Status(name) == utils.LivenessAlive, delegated)
+
+	if expiry, ok := nc.liveness.Expiry(name); ok {
+		if left := time.Until(expiry); left > 0 && (next == 0 || left < next) {
+			next = left
+		}
+	}
+	if next > 0 {
+		nc.healthQueue.AddAfter(name, next)
+	}
+}
@@ -0,0 +1,89 @@
+package poolcoordinator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateNodeHealth(t *testing.T) {
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2", "node3"} {
+		nodes = append(nodes, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{constant.LabelKeyNodePool: "pool1"},
+			},
+		})
+	}
+	nc := newTestController(t, nodes...)
+	defer nc.queue.ShutDown()
+	defer nc.healthQueue.ShutDown()
+
+	nc.liveness.ObserveRenewal(&coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Spec:       coordv1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: time.Now()}},
+	}, time.Now())
+	for i := 0; i < constant.LeaseDelegationThreshold; i++ {
+		ldc.Inc("node2")
+	}
+	for _, n := range []string{"node1", "node2", "node3", "missing"} {
+		nc.updateNodeHealth(n)
+	}
+
+	expected := utils.PoolHealthStats{Nodes: 3, Alive: 1, Delegated: 1}
+	if got := nc.health.Stats("pool1"); got != expected {
+		t.Errorf("expect %+v, but %+v returned", expected, got)
+	}
+
+	// lease of node1 is gone
+	nc.liveness.Forget("node1")
+	nc.updateNodeHealth("node1")
+	expected.Alive = 0
+	if got := nc.health.Stats("pool1"); got != expected {
+		t.Errorf("expect %+v, but %+v returned", expected, got)
+	}
+}
+
+func TestNodeHealthScheduled(t *testing.T) {
+	nc := newTestController(t, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{constant.LabelKeyNodePool: "pool1"},
+		},
+	})
+	defer nc.queue.ShutDown()
+	defer nc.healthQueue.ShutDown()
+
+	seconds := int32(1)
+	nc.liveness.ObserveRenewal(&coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Spec: coordv1.LeaseSpec{
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &metav1.MicroTime{Time: time.Now()},
+		},
+	}, time.Now())
+	nc.updateNodeHealth("node1")
+	if got := nc.health.Stats("pool1").Alive; got != 1 {
+		t.Errorf("expect %v, but %v returned", 1, got)
+	}
+	if got := nc.healthQueue.Len(); got != 0 {
+		t.Errorf("expect nothing queued before the lease times out, but %v returned", got)
+	}
+
+	// the lease times out without any event
+	key, _ := nc.healthQueue.Get()
+	nc.updateNodeHealth(key.(string))
+	nc.healthQueue.Done(key)
+	if got := nc.health.Stats("pool1").Alive; got != 0 {
+		t.Errorf("expect %v, but %v returned", 0, got)
+	}
+	if got := nc.healthQueue.Len(); got != 0 {
+		t.Errorf("expect a dead node not scheduled again, but %v returned", got)
+	}
+}
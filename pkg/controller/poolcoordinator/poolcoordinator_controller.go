@@ -2,33 +2,59 @@ package poolcoordinator
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
+	"time"
 
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/client"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/lister"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/metrics"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/webhook"
 	coordv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
 	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 )
 
 type Controller struct {
-	client      *kubernetes.Clientset
+	cfg         *config.Config
+	client      kubernetes.Interface
 	nodeLister  listerv1.NodeLister
+	podLister   listerv1.PodLister
 	leaseLister leaselisterv1.LeaseNamespaceLister
-	nodepoolMap *utils.NodepoolMap
+	pools       *utils.Pools
+	health      *utils.PoolHealth
+	liveness    *utils.LivenessTracker
+	partitions  *utils.PartitionDetector
+	recorder    record.EventRecorder
+	// node names whose pool health needs updating
+	healthQueue workqueue.DelayingInterface
+	// node names whose unschedulable taint, delegated condition or autonomy annotation needs reconciling
+	queue workqueue.RateLimitingInterface
+	// 1 while this replica holds the controller lease
+	leading int32
 }
 
+// LeaseDelegatedCounter tracks, per node, the streak of heartbeats delegated by the pool coordinator
 type LeaseDelegatedCounter struct {
-	v    map[string]int
-	lock sync.RWMutex
+	v map[string]int
+	// when the current streak of delegated heartbeats started
+	since     map[string]time.Time
+	threshold int
+	window    time.Duration
+	lock      sync.RWMutex
 }
 
 var (
@@ -37,11 +63,23 @@ var (
 	ldc *LeaseDelegatedCounter
 )
 
+func NewLeaseDelegatedCounter(dc config.DelegationConfig) *LeaseDelegatedCounter {
+	return &LeaseDelegatedCounter{
+		v:         make(map[string]int),
+		since:     make(map[string]time.Time),
+		threshold: dc.Threshold,
+		window:    dc.Window.Duration,
+	}
+}
+
 func (dc *LeaseDelegatedCounter) Inc(name string) {
 	dc.lock.Lock()
 	defer dc.lock.Unlock()
 
-	if dc.v[name] >= constant.LeaseDelegationThreshold {
+	if dc.v[name] == 0 {
+		dc.since[name] = time.Now()
+	}
+	if dc.v[name] >= dc.threshold {
 		return
 	}
 	dc.v[name] += 1
@@ -54,6 +92,9 @@ func (dc *LeaseDelegatedCounter) Dec(name string) {
 	if dc.v[name] > 0 {
 		dc.v[name] -= 1
 	}
+	if dc.v[name] == 0 {
+		delete(dc.since, name)
+	}
 }
 
 func (dc *LeaseDelegatedCounter) Reset(name string) {
@@ -61,6 +102,7 @@ func (dc *LeaseDelegatedCounter) Reset(name string) {
 	defer dc.lock.Unlock()
 
 	dc.v[name] = 0
+	delete(dc.since, name)
 }
 
 func (dc *LeaseDelegatedCounter) Touch(name string) {
@@ -68,85 +110,163 @@ func (dc *LeaseDelegatedCounter) Touch(name string) {
 	defer dc.lock.Unlock()
 
 	if _, ok := dc.v[name]; !ok {
-		dc.Reset(name)
+		dc.v[name] = 0
 	}
 }
 
+// Streak returns the number of delegated heartbeats of node name counted, up to the threshold,
+// and when they started. It returns false if the node is in no streak.
+func (dc *LeaseDelegatedCounter) Streak(name string) (int, time.Time, bool) {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+
+	since, ok := dc.since[name]
+	return dc.v[name], since, ok
+}
+
 func (dc *LeaseDelegatedCounter) Counter(name string) int {
 	dc.lock.RLock()
-	defer dc.lock.Unlock()
+	defer dc.lock.RUnlock()
 
 	return dc.v[name]
 }
 
+// Delegated tells whether the heartbeat of node name is considered delegated. In window
+// mode, a node in a streak that is not yet long enough also gets the time left until it is.
+func (dc *LeaseDelegatedCounter) Delegated(name string) (bool, time.Duration) {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+
+	if dc.window <= 0 {
+		return dc.v[name] >= dc.threshold, 0
+	}
+	since, ok := dc.since[name]
+	if !ok {
+		return false, 0
+	}
+	left := dc.window - time.Since(since)
+	if left <= 0 {
+		return true, 0
+	}
+	return false, left
+}
+
+// isHeartbeat tells whether an update of a lease is a renewal rather than an informer resync
+func isHeartbeat(ol, nl *coordv1.Lease) bool {
+	if ol.ResourceVersion != nl.ResourceVersion {
+		return true
+	}
+	if ol.Spec.RenewTime == nil || nl.Spec.RenewTime == nil {
+		return ol.Spec.RenewTime != nl.Spec.RenewTime
+	}
+	return !ol.Spec.RenewTime.Equal(nl.Spec.RenewTime)
+}
+
+func isDelegated(l *coordv1.Lease) bool {
+	return l.Annotations[constant.DelegateHeartBeat] == "true"
+}
+
 func onLeaseCreate(n interface{}) {
 	nl := n.(*coordv1.Lease)
-	//klog.Infof("new lease: %v\n", nl)
+	GetController().liveness.Observe(nl)
 	ldc.Reset(nl.Name)
 
-	if val, ok := nl.Annotations[constant.DelegateHeartBeat]; ok {
-		if val == "true" {
-			ldc.Inc(nl.Name)
-		}
+	if isDelegated(nl) {
+		ldc.Inc(nl.Name)
 	}
+	GetController().enqueue(nl.Name)
+	GetController().healthQueue.Add(nl.Name)
 }
 
 func onLeaseUpdate(o interface{}, n interface{}) {
-	//ol := o.(*coordv1.Lease)
+	ol := o.(*coordv1.Lease)
 	nl := n.(*coordv1.Lease)
-	//klog.Infof("updated lease: %v\n", nl)
 
+	GetController().liveness.Observe(nl)
 	ldc.Touch(nl.Name)
 
-	//oval, ook := ol.Annotations[constant.DelegateHeartBeat]
-	nval, nok := nl.Annotations[constant.DelegateHeartBeat]
+	// periodic resyncs deliver the same lease again, only real renewals count
+	if !isHeartbeat(ol, nl) {
+		return
+	}
 
-	if nok && nval == "true" {
+	// every replica keeps counting, so a new leader starts with warm state,
+	// only the leader's workers reconcile the taint
+	if isDelegated(nl) {
 		ldc.Inc(nl.Name)
-		if ldc.Counter(nl.Name) >= constant.LeaseDelegationThreshold {
-			GetController().taintNodeNotSchedulable(nl.Name)
-		}
 	} else {
-		if ldc.Counter(nl.Name) >= constant.LeaseDelegationThreshold {
-			GetController().deTaintNodeNotSchedulable(nl.Name)
-		}
 		ldc.Reset(nl.Name)
 	}
+	GetController().enqueue(nl.Name)
+	GetController().healthQueue.Add(nl.Name)
+}
+
+func onLeaseDelete(n interface{}) {
+	if d, ok := n.(cache.DeletedFinalStateUnknown); ok {
+		n = d.Obj
+	}
+	nl, ok := n.(*coordv1.Lease)
+	if !ok {
+		return
+	}
+	GetController().liveness.Forget(nl.Name)
+	GetController().enqueue(nl.Name)
+	GetController().healthQueue.Add(nl.Name)
+}
+
+func onNodePoolCreate(n interface{}) {
+	np, err := utils.ParseNodePool(n)
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, name := range np.Nodes {
+		GetController().healthQueue.Add(name)
+		// autonomy may come from the pool
+		GetController().enqueue(name)
+	}
+}
+
+func onNodePoolUpdate(o interface{}, n interface{}) {
+	// members leaving the pool are updated as well
+	onNodePoolCreate(o)
+	onNodePoolCreate(n)
+}
+
+func onNodePoolDelete(n interface{}) {
+	if d, ok := n.(cache.DeletedFinalStateUnknown); ok {
+		n = d.Obj
+	}
+	onNodePoolCreate(n)
 }
 
 func onNodeCreate(n interface{}) {
 	node := n.(*corev1.Node)
-	pool, ok := utils.NodeNodepool(node)
-	if ok {
-		GetController().nodepoolMap.Add(pool, node.Name)
-	}
+	GetController().healthQueue.Add(node.Name)
 }
 
 func onNodeDelete(n interface{}) {
-	node := n.(*corev1.Node)
-	pool, ok := utils.NodeNodepool(node)
-	if ok {
-		GetController().nodepoolMap.Del(pool, node.Name)
+	if d, ok := n.(cache.DeletedFinalStateUnknown); ok {
+		n = d.Obj
 	}
+	node, ok := n.(*corev1.Node)
+	if !ok {
+		return
+	}
+	GetController().healthQueue.Add(node.Name)
 }
 
 func onNodeUpdate(o interface{}, n interface{}) {
 	on := o.(*corev1.Node)
 	nn := n.(*corev1.Node)
-	opool, ook := utils.NodeNodepool(on)
-	npool, nok := utils.NodeNodepool(nn)
-	if !ook && !nok {
-		return
+	opool, _ := utils.NodeNodepool(on)
+	npool, _ := utils.NodeNodepool(nn)
+	if opool != npool {
+		GetController().healthQueue.Add(nn.Name)
 	}
-	if !ook && nok {
-		GetController().nodepoolMap.Add(npool, nn.Name)
-	} else if ook && !nok {
-		GetController().nodepoolMap.Del(opool, on.Name)
-	} else {
-		if opool != npool {
-			GetController().nodepoolMap.Del(opool, on.Name)
-			GetController().nodepoolMap.Add(npool, nn.Name)
-		}
+	if on.Annotations[constant.AnnotationKeyNodeAutonomy] != nn.Annotations[constant.AnnotationKeyNodeAutonomy] ||
+		on.Annotations[constant.AnnotationKeyNodeAutonomyDuration] != nn.Annotations[constant.AnnotationKeyNodeAutonomyDuration] {
+		GetController().enqueue(nn.Name)
 	}
 }
 
@@ -157,7 +277,9 @@ func NewController() *Controller {
 func GetController() *Controller {
 	if ctl == nil {
 		ctl = &Controller{
-			client: client.GetClientFromCluster(),
+			client:      client.GetClientFromCluster(),
+			queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pool-coordinator-taint"),
+			healthQueue: workqueue.NewNamedDelayingQueue("pool-coordinator-health"),
 			//client: client.GetClientFromEnv(os.Getenv("HOME") + "/.kube/config"),
 		}
 	}
@@ -165,68 +287,144 @@ func GetController() *Controller {
 	return ctl
 }
 
-func (nc *Controller) taintNodeNotSchedulable(name string) {
-	node, err := nc.nodeLister.Get(name)
+// taintNodeNotSchedulable adds the unschedulable taint to node name, retrying on conflict
+func (nc *Controller) taintNodeNotSchedulable(name string) error {
+	var tainted *corev1.Node
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nc.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		taints := node.Spec.Taints
+		if utils.TaintKeyExists(taints, constant.NodeNotSchedulableTaint) {
+			return nil
+		}
+		t := corev1.Taint{
+			Key:    constant.NodeNotSchedulableTaint,
+			Value:  "true",
+			Effect: corev1.TaintEffectNoSchedule,
+		}
+		taints = append(append([]corev1.Taint{}, taints...), t)
+		if err := nc.patchNodeTaints(node, taints); err != nil {
+			return err
+		}
+		tainted = node
+		return nil
+	})
 	if err != nil {
-		klog.Error(err)
-		return
-	}
-	taints := node.Spec.Taints
-	if utils.TaintKeyExists(taints, constant.NodeNotSchedulableTaint) {
-		return
+		metrics.TaintOperations.WithLabelValues("taint", "error").Inc()
+		return err
 	}
-	nn := node.DeepCopy()
-	t := corev1.Taint{
-		Key:    constant.NodeNotSchedulableTaint,
-		Value:  "true",
-		Effect: corev1.TaintEffectNoSchedule,
-	}
-	nn.Spec.Taints = append(nn.Spec.Taints, t)
-	nn, err = nc.client.CoreV1().Nodes().Update(context.TODO(), nn, metav1.UpdateOptions{})
-	if err != nil {
-		klog.Error(err)
+	metrics.TaintOperations.WithLabelValues("taint", "success").Inc()
+	if tainted != nil {
+		nc.recorder.Eventf(tainted, corev1.EventTypeWarning, eventReasonTainted,
+			"heartbeat is delegated by the pool coordinator, added taint %s", constant.NodeNotSchedulableTaint)
 	}
+	return nil
 }
 
-func (nc *Controller) deTaintNodeNotSchedulable(name string) {
-	node, err := nc.nodeLister.Get(name)
+// deTaintNodeNotSchedulable removes the unschedulable taint from node name, retrying on conflict
+func (nc *Controller) deTaintNodeNotSchedulable(name string) error {
+	var untainted *corev1.Node
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nc.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		taints, deleted := utils.DeleteTaintsByKey(node.Spec.Taints, constant.NodeNotSchedulableTaint)
+		if !deleted {
+			return nil
+		}
+		if err := nc.patchNodeTaints(node, taints); err != nil {
+			return err
+		}
+		untainted = node
+		return nil
+	})
 	if err != nil {
-		klog.Error(err)
-		return
+		metrics.TaintOperations.WithLabelValues("untaint", "error").Inc()
+		return err
 	}
-	taints := node.Spec.Taints
-	taints, deleted := utils.DeleteTaintsByKey(taints, constant.NodeNotSchedulableTaint)
-	if !deleted {
-		return
+	metrics.TaintOperations.WithLabelValues("untaint", "success").Inc()
+	if untainted != nil {
+		nc.recorder.Eventf(untainted, corev1.EventTypeNormal, eventReasonUntainted,
+			"heartbeat is no longer delegated, removed taint %s", constant.NodeNotSchedulableTaint)
+	}
+	return nil
+}
+
+// patchNodeTaints replaces taints of node, failing with a conflict if node changed meanwhile
+func (nc *Controller) patchNodeTaints(node *corev1.Node, taints []corev1.Taint) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": node.ResourceVersion,
+		},
+		"spec": map[string]interface{}{
+			"taints": taints,
+		},
 	}
-	nn := node.DeepCopy()
-	nn.Spec.Taints = taints
-	nn, err = nc.client.CoreV1().Nodes().Update(context.TODO(), nn, metav1.UpdateOptions{})
+	data, err := json.Marshal(patch)
 	if err != nil {
-		klog.Error(err)
+		return err
+	}
+	_, err = nc.client.CoreV1().Nodes().Patch(context.TODO(), node.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+// poolStats summarizes node health of every nodepool for metrics
+func (nc *Controller) poolStats() []metrics.PoolStats {
+	pools := nc.health.Pools()
+	stats := make([]metrics.PoolStats, 0, len(pools))
+	for _, pool := range pools {
+		h := nc.health.Stats(pool)
+		s := metrics.PoolStats{
+			Pool:      pool,
+			Nodes:     h.Nodes,
+			Alive:     h.Alive,
+			Delegated: h.Delegated,
+			State:     string(nc.partitions.State(pool)),
+		}
+		for _, name := range nc.pools.Nodes(pool) {
+			node, err := nc.nodeLister.Get(name)
+			if err == nil && utils.TaintKeyExists(node.Spec.Taints, constant.NodeNotSchedulableTaint) {
+				s.Tainted++
+			}
+		}
+		stats = append(stats, s)
 	}
+	return stats
 }
 
-func (nc *Controller) Run() {
+func (nc *Controller) Run(cfg *config.Config) {
+	nc.cfg = cfg
 	stopCH := make(chan (struct{}))
 	stopper := make(chan (struct{}))
 	defer close(stopper)
-	ldc = &LeaseDelegatedCounter{
-		v: make(map[string]int),
-	}
+	defer nc.queue.ShutDown()
+	ldc = NewLeaseDelegatedCounter(cfg.Delegation)
+	nc.liveness = utils.NewLivenessTracker(cfg.Liveness.LeaseDurationMultiplier)
+	nc.recorder = client.NewEventRecorder(nc.client)
+	nc.partitions = utils.NewPartitionDetector(cfg.Partition.DegradedRatio, cfg.Partition.PartitionedRatio, cfg.Partition.Hysteresis.Duration)
 
 	klog.Info("create lease lister")
-	nc.leaseLister = lister.CreateLeaseLister(nc.client, stopper, onLeaseCreate, onLeaseUpdate, nil)
+	nc.leaseLister = lister.CreateLeaseLister(nc.client, stopper, onLeaseCreate, onLeaseUpdate, onLeaseDelete)
+	klog.Info("create nodepool lister")
+	lister.CreateNodePoolLister(nc.client, client.GetDynamicClientFromCluster(), stopper, onNodePoolCreate, onNodePoolUpdate, onNodePoolDelete)
 	klog.Info("create node lister")
 	nc.nodeLister = lister.CreateNodeLister(nc.client, stopper, onNodeCreate, onNodeUpdate, onNodeDelete)
-	klog.Info("create nodepool map")
-	nc.nodepoolMap = utils.NewNodepoolMap()
-	nl, err := nc.nodeLister.List(labels.Everything())
-	if err != nil {
-		klog.Error(err)
-	}
-	nc.nodepoolMap.Sync(nl)
+	klog.Info("create pod lister")
+	nc.podLister = lister.CreatePodLister(nc.client, stopper, nil, nil, nil)
+	klog.Info("create pdb lister")
+	pdbLister := lister.CreatePDBLister(nc.client, stopper, nil, nil, nil)
+	// pools come from NodePools if served, otherwise from node labels
+	nc.pools = utils.NewPools(lister.NodeIndexer(), lister.NodePoolIndexer())
+	nc.health = utils.NewPoolHealth()
+	go nc.runPoolHealth(stopper)
+	go wait.Until(nc.detectPartitions, partitionDetectInterval, stopper)
+	metrics.RegisterPoolCollector(nc.poolStats)
+	klog.Info("start leader election")
+	go nc.runLeaderElection(stopper)
 	klog.Info("create webhook")
-	go webhook.Run(nc.nodeLister, nc.leaseLister, nc.nodepoolMap)
+	go webhook.Run(nc.cfg, nc.nodeLister, nc.podLister, lister.PodIndexer(), pdbLister, nc.liveness, nc.pools, nc.health, nc.recorder)
 	<-stopCH
 }
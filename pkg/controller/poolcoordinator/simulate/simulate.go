@@ -0,0 +1,331 @@
+package simulate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/inspect"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/webhook"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	policylisterv1 "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// Fixtures are the objects a decision is simulated on
+type Fixtures struct {
+	Nodes     []*corev1.Node
+	Leases    []*coordv1.Lease
+	Pods      []*corev1.Pod
+	PDBs      []*policyv1.PodDisruptionBudget
+	NodePools []*unstructured.Unstructured
+}
+
+// LoadDir reads the manifests of every .yaml, .yml and .json file in dir. A file may hold
+// several documents, and lists as printed by kubectl get -o yaml.
+func LoadDir(dir string) (*Fixtures, error) {
+	f := &Fixtures{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := f.load(data); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load adds the objects of every document in data
+func (f *Fixtures) load(data []byte) error {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := f.add(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// add adds the object in raw, by its kind
+func (f *Fixtures) add(raw []byte) error {
+	tm := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &tm); err != nil {
+		return err
+	}
+	var err error
+	switch tm.Kind {
+	case "List", "NodeList", "LeaseList", "PodList", "PodDisruptionBudgetList", "NodePoolList":
+		list := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err = json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if err = f.add(item); err != nil {
+				return err
+			}
+		}
+	case "Node":
+		node := &corev1.Node{}
+		if err = json.Unmarshal(raw, node); err == nil {
+			f.Nodes = append(f.Nodes, node)
+		}
+	case "Lease":
+		lease := &coordv1.Lease{}
+		if err = json.Unmarshal(raw, lease); err == nil {
+			if lease.Namespace == "" {
+				lease.Namespace = corev1.NamespaceNodeLease
+			}
+			f.Leases = append(f.Leases, lease)
+		}
+	case "Pod":
+		pod := &corev1.Pod{}
+		if err = json.Unmarshal(raw, pod); err == nil {
+			if pod.Namespace == "" {
+				pod.Namespace = metav1.NamespaceDefault
+			}
+			f.Pods = append(f.Pods, pod)
+		}
+	case "PodDisruptionBudget":
+		pdb := &policyv1.PodDisruptionBudget{}
+		if err = json.Unmarshal(raw, pdb); err == nil {
+			if pdb.Namespace == "" {
+				pdb.Namespace = metav1.NamespaceDefault
+			}
+			f.PDBs = append(f.PDBs, pdb)
+		}
+	case "NodePool":
+		np := &unstructured.Unstructured{}
+		if err = np.UnmarshalJSON(raw); err == nil {
+			f.NodePools = append(f.NodePools, np)
+		}
+	default:
+		return fmt.Errorf("unsupported kind %q", tm.Kind)
+	}
+	return err
+}
+
+// Shift moves lease renewals by d, e.g. to replay at present fixtures taken d ago
+func (f *Fixtures) Shift(d time.Duration) {
+	for _, l := range f.Leases {
+		if l.Spec.RenewTime != nil {
+			l.Spec.RenewTime = &metav1.MicroTime{Time: l.Spec.RenewTime.Add(d)}
+		}
+		for i, mf := range l.ManagedFields {
+			if mf.Time != nil {
+				l.ManagedFields[i].Time = &metav1.Time{Time: mf.Time.Add(d)}
+			}
+		}
+	}
+}
+
+// State loads the fixtures into indexer-backed listers, as the informers would hold them.
+// Leases count as renewed when the API server recorded writing them in their managed fields,
+// or at their renew time if trustRenewTime is set.
+func (f *Fixtures) State(cfg *config.Config, trustRenewTime bool) (*inspect.State, error) {
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodeIndexers())
+	podIndexers := utils.PodIndexers()
+	podIndexers[cache.NamespaceIndex] = cache.MetaNamespaceIndexFunc
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, podIndexers)
+	leases := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	pdbs := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	var nodepools cache.Indexer
+	if len(f.NodePools) > 0 {
+		nodepools = cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodePoolIndexers())
+	}
+
+	for _, node := range f.Nodes {
+		if err := nodes.Add(node); err != nil {
+			return nil, err
+		}
+	}
+	for _, pod := range f.Pods {
+		if err := pods.Add(pod); err != nil {
+			return nil, err
+		}
+	}
+	for _, lease := range f.Leases {
+		if err := leases.Add(lease); err != nil {
+			return nil, err
+		}
+	}
+	for _, pdb := range f.PDBs {
+		if err := pdbs.Add(pdb); err != nil {
+			return nil, err
+		}
+	}
+	for _, np := range f.NodePools {
+		if err := nodepools.Add(np); err != nil {
+			return nil, err
+		}
+	}
+
+	st := &inspect.State{
+		Config:     cfg,
+		Nodes:      listerv1.NewNodeLister(nodes),
+		Pods:       listerv1.NewPodLister(pods),
+		PodIndexer: pods,
+		PDBs:       policylisterv1.NewPodDisruptionBudgetLister(pdbs),
+		Leases:     leaselisterv1.NewLeaseLister(leases).Leases(corev1.NamespaceNodeLease),
+		Pools:      utils.NewPools(nodes, nodepools),
+
+		TrustRenewTime: trustRenewTime,
+	}
+	st.Sync()
+	return st, nil
+}
+
+// LoadReview reads an AdmissionReview, in JSON or YAML, from file name
+func LoadReview(name string) (*admissionv1.AdmissionReview, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	review := &admissionv1.AdmissionReview{}
+	if err := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(review); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if review.Request == nil {
+		return nil, fmt.Errorf("%s: no request in admission review", name)
+	}
+	return review, nil
+}
+
+// NewReview builds the AdmissionReview the API server would send for operation, one of
+// evict, delete, create or update, of pod by user
+func NewReview(operation string, pod *corev1.Pod, user authenticationv1.UserInfo) (*admissionv1.AdmissionReview, error) {
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+	req := &admissionv1.AdmissionRequest{
+		UID:       types.UID("simulated"),
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		UserInfo:  user,
+	}
+	switch operation {
+	case "evict":
+		eviction := &policyv1.Eviction{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "policy/v1", Kind: "Eviction"},
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		}
+		if raw, err = json.Marshal(eviction); err != nil {
+			return nil, err
+		}
+		req.Kind = metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"}
+		req.SubResource = "eviction"
+		req.Operation = admissionv1.Create
+		req.Object = runtime.RawExtension{Raw: raw}
+	case "delete":
+		req.Operation = admissionv1.Delete
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	case "create":
+		req.Operation = admissionv1.Create
+		req.Object = runtime.RawExtension{Raw: raw}
+	case "update":
+		req.Operation = admissionv1.Update
+		req.Object = runtime.RawExtension{Raw: raw}
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	default:
+		return nil, fmt.Errorf("unsupported operation %q, expect evict, delete, create or update", operation)
+	}
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  req,
+	}, nil
+}
+
+// Run answers review on st through the webhook handler the API server would call: the
+// mutating one for pod creates and updates, the validating one otherwise. It returns the
+// name of that webhook along with its answer, which is returned along with an error too
+// if the webhook failed on the request.
+func Run(st *inspect.State, review *admissionv1.AdmissionReview) (string, *admissionv1.AdmissionReview, error) {
+	webhook.Setup(st.Config, st.Nodes, st.Pods, st.PodIndexer, st.PDBs, st.Liveness, st.Pools, st.Health, &record.FakeRecorder{})
+
+	req := review.Request
+	if req.Kind.Kind == "Pod" && (req.Operation == admissionv1.Create || req.Operation == admissionv1.Update) {
+		out, err := webhook.Mutate(review)
+		return "mutate", out, err
+	}
+	out, err := webhook.Validate(review)
+	return "validate", out, err
+}
+
+// Print prints the decision, reason and patch of the answer out of webhook name
+func Print(w io.Writer, name string, out *admissionv1.AdmissionReview) error {
+	if out == nil || out.Response == nil {
+		return fmt.Errorf("webhook %s gave no answer", name)
+	}
+	resp := out.Response
+	decision := "denied"
+	if resp.Allowed {
+		decision = "allowed"
+	}
+	fmt.Fprintf(w, "Webhook:   %s\n", name)
+	fmt.Fprintf(w, "Decision:  %s\n", decision)
+	if resp.Result != nil {
+		fmt.Fprintf(w, "Code:      %d\n", resp.Result.Code)
+		fmt.Fprintf(w, "Reason:    %s\n", resp.Result.Message)
+		if resp.Result.Details != nil && resp.Result.Details.RetryAfterSeconds > 0 {
+			fmt.Fprintf(w, "Retry in:  %ds\n", resp.Result.Details.RetryAfterSeconds)
+		}
+	}
+	for _, warning := range resp.Warnings {
+		fmt.Fprintf(w, "Warning:   %s\n", warning)
+	}
+	if len(resp.Patch) > 0 {
+		patch := &bytes.Buffer{}
+		if err := json.Indent(patch, resp.Patch, "", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Patch:\n%s\n", patch.String())
+	}
+	return nil
+}
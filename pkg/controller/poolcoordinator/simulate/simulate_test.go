@@ -0,0 +1,168 @@
+package simulate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func writeFixtures(t *testing.T, renew time.Time) string {
+	dir := t.TempDir()
+	nodes := ""
+	leases := "apiVersion: v1\nkind: List\nitems:\n"
+	for i, name := range []string{"node1", "node2", "node3"} {
+		nodes += fmt.Sprintf("---\napiVersion: v1\nkind: Node\nmetadata:\n  name: %s\n  labels:\n    %s: pool1\n", name, constant.LabelKeyNodePool)
+		at := renew
+		if i == 0 {
+			at = renew.Add(-time.Minute)
+		}
+		leases += fmt.Sprintf("- apiVersion: coordination.k8s.io/v1\n  kind: Lease\n  metadata:\n    name: %s\n"+
+			"    managedFields:\n    - manager: kubelet\n      operation: Update\n      time: %q\n"+
+			"  spec:\n    leaseDurationSeconds: 40\n    renewTime: %q\n",
+			name, at.UTC().Format(time.RFC3339), at.UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	}
+	pod := fmt.Sprintf(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "namespace": "default", "annotations": {%q: %q}}, "spec": {"nodeName": "node1"}}`,
+		constant.PodAvailableAnnotation, constant.PodAvailableNode)
+
+	files := map[string]string{"nodes.yaml": nodes, "leases.yml": leases, "pod.json": pod, "README.md": "not a manifest"}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	f, err := LoadDir(writeFixtures(t, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Nodes) != 3 || len(f.Leases) != 3 || len(f.Pods) != 1 {
+		t.Errorf("expect 3 nodes, 3 leases and 1 pod, but %d, %d and %d returned", len(f.Nodes), len(f.Leases), len(f.Pods))
+	}
+	if f.Leases[0].Namespace != "kube-node-lease" {
+		t.Errorf("expect %v, but %v returned", "kube-node-lease", f.Leases[0].Namespace)
+	}
+
+	st, err := f.State(config.Default(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for node, expect := range map[string]utils.Liveness{"node1": utils.LivenessDead, "node2": utils.LivenessAlive} {
+		if got := st.Liveness.Status(node); got != expect {
+			t.Errorf("%s: expect %v, but %v returned", node, expect, got)
+		}
+	}
+
+	renew := f.Leases[1].Spec.RenewTime.Time
+	written := f.Leases[1].ManagedFields[0].Time.Time
+	f.Shift(time.Hour)
+	if got := f.Leases[1].Spec.RenewTime.Time; !got.Equal(renew.Add(time.Hour)) {
+		t.Errorf("expect %v, but %v returned", renew.Add(time.Hour), got)
+	}
+	if got := f.Leases[1].ManagedFields[0].Time.Time; !got.Equal(written.Add(time.Hour)) {
+		t.Errorf("expect %v, but %v returned", written.Add(time.Hour), got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	user := authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"}
+	tests := []struct {
+		name      string
+		operation string
+		taken     time.Time
+		shift     time.Duration
+		webhook   string
+		want      []string
+	}{
+		{"eviction on dead node", "evict", time.Now(), 0, "validate", []string{"Decision:  denied"}},
+		{"eviction replayed", "evict", time.Now().Add(-time.Hour), time.Hour, "validate", []string{"Decision:  denied"}},
+		{"delete", "delete", time.Now(), 0, "validate", []string{"Decision:  denied"}},
+		{"create", "create", time.Now(), 0, "mutate", []string{"Decision:  allowed", "Patch:", "tolerations"}},
+	}
+	for _, tt := range tests {
+		f, err := LoadDir(writeFixtures(t, tt.taken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Shift(tt.shift)
+		st, err := f.State(config.Default(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		review, err := NewReview(tt.operation, f.Pods[0], user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, out, err := Run(st, review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != tt.webhook {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.webhook, name)
+		}
+		buf := &bytes.Buffer{}
+		if err := Print(buf, name, out); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: expect %q in output, but %q returned", tt.name, want, buf.String())
+			}
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	f, err := LoadDir(writeFixtures(t, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := f.State(config.Default(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"}
+
+	missing := f.Pods[0].DeepCopy()
+	missing.Name = "missing"
+	tests := []struct {
+		name      string
+		operation string
+		pod       *corev1.Pod
+	}{
+		{"missing pod", "evict", missing},
+	}
+	for _, tt := range tests {
+		review, err := NewReview(tt.operation, tt.pod, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, out, err := Run(st, review)
+		if err == nil {
+			t.Errorf("%s: expect error, but nil returned", tt.name)
+		}
+		buf := &bytes.Buffer{}
+		if err := Print(buf, name, out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "Code:      400") {
+			t.Errorf("%s: expect %q in output, but %q returned", tt.name, "Code:      400", buf.String())
+		}
+	}
+
+	if err := Print(&bytes.Buffer{}, "validate", nil); err == nil {
+		t.Errorf("expect error for no answer, but nil returned")
+	}
+}
@@ -0,0 +1,135 @@
+package poolcoordinator
+
+import (
+	"context"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	taintWorkers = 2
+	// a node failing this many times in a row is dropped until its lease changes again
+	maxTaintRetries = 15
+
+	// reasons of the events on nodes whose unschedulable taint changes
+	eventReasonTainted   = "PoolCoordinatorTainted"
+	eventReasonUntainted = "PoolCoordinatorUntainted"
+)
+
+// enqueue schedules node name for reconciliation
+func (nc *Controller) enqueue(name string) {
+	nc.queue.Add(name)
+}
+
+// startLeading resyncs every node, then reconciles taints until ctx is done. The resync
+// cleans up stale taints, e.g. left behind by a crash while the node was delegated.
+func (nc *Controller) startLeading(ctx context.Context) {
+	nodes, err := nc.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+	}
+	for _, node := range nodes {
+		nc.enqueue(node.Name)
+	}
+
+	for i := 0; i < taintWorkers; i++ {
+		go wait.UntilWithContext(ctx, nc.runWorker, time.Second)
+	}
+}
+
+func (nc *Controller) runWorker(ctx context.Context) {
+	for nc.processNextItem(ctx) {
+	}
+}
+
+func (nc *Controller) processNextItem(ctx context.Context) bool {
+	key, quit := nc.queue.Get()
+	if quit {
+		return false
+	}
+	defer nc.queue.Done(key)
+
+	// lost leadership, keep the node queued for when we lead again
+	if ctx.Err() != nil || !nc.isLeader() {
+		nc.queue.Add(key)
+		return false
+	}
+
+	name := key.(string)
+	err := nc.reconcile(name)
+	if err == nil {
+		nc.queue.Forget(key)
+		return true
+	}
+
+	if nc.queue.NumRequeues(key) < maxTaintRetries {
+		klog.Warningf("could not reconcile node %s, retrying: %v", name, err)
+		nc.queue.AddRateLimited(key)
+		return true
+	}
+	klog.Errorf("could not reconcile node %s, dropping: %v", name, err)
+	nc.queue.Forget(key)
+	return true
+}
+
+// reconcile brings the taint, the delegated condition and the autonomy annotation of node name up to date
+func (nc *Controller) reconcile(name string) error {
+	if err := nc.reconcileTaint(name); err != nil {
+		return err
+	}
+	if err := nc.reconcileCondition(name); err != nil {
+		return err
+	}
+	return nc.reconcileAutonomy(name)
+}
+
+// reconcileTaint makes the unschedulable taint of node name reflect its lease history
+func (nc *Controller) reconcileTaint(name string) error {
+	node, err := nc.nodeLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	desired, left := ldc.Delegated(name)
+	if left > 0 {
+		// check again once the streak covers the whole window
+		nc.queue.AddAfter(name, left)
+	}
+	tainted := utils.TaintKeyExists(node.Spec.Taints, constant.NodeNotSchedulableTaint)
+	switch {
+	case desired && !tainted:
+		klog.Infof("heartbeat of node %s is delegated, tainting it unschedulable", name)
+		return nc.taintNodeNotSchedulable(name)
+	case !desired && tainted:
+		// the count starts over when the controller restarts, keep the taint while the lease
+		// is still delegated, the streak will catch up
+		delegated, err := nc.leaseDelegated(name)
+		if err != nil || delegated {
+			return err
+		}
+		klog.Infof("heartbeat of node %s is not delegated, removing unschedulable taint", name)
+		return nc.deTaintNodeNotSchedulable(name)
+	}
+	return nil
+}
+
+// leaseDelegated tells whether the current lease of node name is delegated
+func (nc *Controller) leaseDelegated(name string) (bool, error) {
+	lease, err := nc.leaseLister.Get(name)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return isDelegated(lease), nil
+}
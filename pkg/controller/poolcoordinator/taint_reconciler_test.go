@@ -0,0 +1,236 @@
+package poolcoordinator
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newTestController(t *testing.T, nodes ...*corev1.Node) *Controller {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodeIndexers())
+	objs := []runtime.Object{}
+	for _, n := range nodes {
+		if err := indexer.Add(n); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, n)
+	}
+	ldc = NewLeaseDelegatedCounter(config.Default().Delegation)
+	ctl = &Controller{
+		client:      fake.NewSimpleClientset(objs...),
+		nodeLister:  listerv1.NewNodeLister(indexer),
+		leaseLister: leaselisterv1.NewLeaseLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})).Leases(corev1.NamespaceNodeLease),
+		queue:       workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		liveness:    utils.NewLivenessTracker(1),
+		pools:       utils.NewPools(indexer, nil),
+		health:      utils.NewPoolHealth(),
+		healthQueue: workqueue.NewDelayingQueue(),
+		recorder:    record.NewFakeRecorder(100),
+		partitions:  utils.NewPartitionDetector(0.2, 0.6, 0),
+	}
+	return ctl
+}
+
+func TestReconcileTaint(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	}
+	stale := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node2"},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: constant.NodeNotSchedulableTaint, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	nc := newTestController(t, node, stale)
+	defer nc.queue.ShutDown()
+
+	// delegated node gets tainted
+	for i := 0; i < constant.LeaseDelegationThreshold; i++ {
+		ldc.Inc("node1")
+	}
+	if err := nc.reconcileTaint("node1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := nc.client.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.TaintKeyExists(got.Spec.Taints, constant.NodeNotSchedulableTaint) {
+		t.Errorf("expect node1 tainted, but taints are %v", got.Spec.Taints)
+	}
+	if e := <-nc.recorder.(*record.FakeRecorder).Events; !strings.Contains(e, eventReasonTainted) {
+		t.Errorf("expect event %s, but %q returned", eventReasonTainted, e)
+	}
+
+	// an existing taint is left alone, without another event
+	if err := nc.taintNodeNotSchedulable("node1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(nc.recorder.(*record.FakeRecorder).Events); n != 0 {
+		t.Errorf("expect %v, but %v returned", 0, n)
+	}
+
+	// stale taint without delegation is removed
+	if err := nc.reconcileTaint("node2"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = nc.client.CoreV1().Nodes().Get(context.TODO(), "node2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utils.TaintKeyExists(got.Spec.Taints, constant.NodeNotSchedulableTaint) {
+		t.Errorf("expect node2 untainted, but taints are %v", got.Spec.Taints)
+	}
+	if e := <-nc.recorder.(*record.FakeRecorder).Events; !strings.Contains(e, eventReasonUntainted) {
+		t.Errorf("expect event %s, but %q returned", eventReasonUntainted, e)
+	}
+
+	// unknown nodes are ignored
+	if err := nc.reconcileTaint("node3"); err != nil {
+		t.Errorf("expect nil, but %v returned", err)
+	}
+}
+
+func TestStartLeadingKeepsDelegatedTaint(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: constant.NodeNotSchedulableTaint, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	nc := newTestController(t, node)
+	defer nc.queue.ShutDown()
+
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "node1",
+			Namespace:       corev1.NamespaceNodeLease,
+			ResourceVersion: "1",
+			Annotations:     map[string]string{constant.DelegateHeartBeat: "true"},
+		},
+		Spec: coordv1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: time.Now()}},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(lease); err != nil {
+		t.Fatal(err)
+	}
+	nc.leaseLister = leaselisterv1.NewLeaseLister(indexer).Leases(corev1.NamespaceNodeLease)
+
+	// a restarted controller counts the delegated lease once, on the initial sync
+	onLeaseCreate(lease)
+	if delegated, _ := ldc.Delegated("node1"); delegated {
+		t.Fatalf("expect %v, but %v returned", false, delegated)
+	}
+
+	// run the resync in this goroutine, instead of workers
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nc.leading = 1
+	nc.startLeading(ctx)
+	for nc.queue.Len() > 0 {
+		nc.processNextItem(context.Background())
+	}
+
+	got, err := nc.client.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utils.TaintKeyExists(got.Spec.Taints, constant.NodeNotSchedulableTaint) {
+		t.Errorf("expect node1 tainted, but taints are %v", got.Spec.Taints)
+	}
+	for len(nc.recorder.(*record.FakeRecorder).Events) > 0 {
+		if e := <-nc.recorder.(*record.FakeRecorder).Events; strings.Contains(e, eventReasonUntainted) {
+			t.Errorf("expect no event %s, but %q returned", eventReasonUntainted, e)
+		}
+	}
+}
+
+func TestLeaseUpdateIgnoresResync(t *testing.T) {
+	nc := newTestController(t)
+	defer nc.queue.ShutDown()
+
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "node1",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{constant.DelegateHeartBeat: "true"},
+		},
+		Spec: coordv1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: time.Now()}},
+	}
+	onLeaseCreate(lease)
+
+	// resyncs deliver the same lease over and over
+	for i := 0; i < 2*constant.LeaseDelegationThreshold; i++ {
+		onLeaseUpdate(lease, lease)
+	}
+	if delegated, _ := ldc.Delegated("node1"); delegated {
+		t.Errorf("expect %v, but %v returned", false, delegated)
+	}
+
+	// real renewals count
+	for i := 2; i <= constant.LeaseDelegationThreshold; i++ {
+		renewed := lease.DeepCopy()
+		renewed.ResourceVersion = strconv.Itoa(i)
+		renewed.Spec.RenewTime = &metav1.MicroTime{Time: lease.Spec.RenewTime.Add(10 * time.Second)}
+		onLeaseUpdate(lease, renewed)
+		lease = renewed
+	}
+	if delegated, _ := ldc.Delegated("node1"); !delegated {
+		t.Errorf("expect %v, but %v returned", true, delegated)
+	}
+
+	// a direct heartbeat ends the streak
+	direct := lease.DeepCopy()
+	direct.ResourceVersion = "100"
+	direct.Annotations = nil
+	onLeaseUpdate(lease, direct)
+	if delegated, _ := ldc.Delegated("node1"); delegated {
+		t.Errorf("expect %v, but %v returned", false, delegated)
+	}
+}
+
+func TestDelegationWindow(t *testing.T) {
+	dc := NewLeaseDelegatedCounter(config.DelegationConfig{
+		Threshold: constant.LeaseDelegationThreshold,
+		Window:    metav1.Duration{Duration: time.Minute},
+	})
+
+	for i := 0; i < 2*constant.LeaseDelegationThreshold; i++ {
+		dc.Inc("node1")
+	}
+	delegated, left := dc.Delegated("node1")
+	if delegated || left <= 0 || left > time.Minute {
+		t.Errorf("expect not delegated with time left, but %v, %v returned", delegated, left)
+	}
+
+	// streak started long enough ago
+	dc.since["node1"] = time.Now().Add(-2 * time.Minute)
+	if delegated, _ := dc.Delegated("node1"); !delegated {
+		t.Errorf("expect %v, but %v returned", true, delegated)
+	}
+
+	dc.Reset("node1")
+	if delegated, left := dc.Delegated("node1"); delegated || left != 0 {
+		t.Errorf("expect not delegated, but %v, %v returned", delegated, left)
+	}
+}
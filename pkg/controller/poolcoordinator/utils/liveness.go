@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+)
+
+const (
+	// kubelet's default nodeLeaseDurationSeconds, used when a lease does not tell its duration
+	defaultLeaseDuration = 40 * time.Second
+)
+
+type Liveness int
+
+const (
+	// LivenessUnknown means we have no lease observation to decide on, e.g. the lease is
+	// missing or not seen yet. Callers must not treat such a node as dead.
+	LivenessUnknown Liveness = iota
+	LivenessAlive
+	LivenessDead
+)
+
+func (l Liveness) String() string {
+	switch l {
+	case LivenessAlive:
+		return "Alive"
+	case LivenessDead:
+		return "Dead"
+	}
+	return "Unknown"
+}
+
+type leaseObservation struct {
+	renewTime time.Time
+	// local time the renewal was observed at, immune to the node's clock skew. Until a
+	// renewal is observed, the time the lease was first seen at.
+	observed time.Time
+	renewed  bool
+	duration time.Duration
+}
+
+// LivenessTracker decides node liveness from node leases. It records when the controller
+// itself observed each renewal, so a skewed clock of an edge node does not matter, and
+// times out a node after its LeaseDurationSeconds times multiplier. A lease seen for the
+// first time, e.g. after a restart, tells nothing about the node until it is renewed or
+// one timeout has passed without renewal.
+type LivenessTracker struct {
+	leases     map[string]leaseObservation
+	multiplier float64
+	lock       sync.RWMutex
+}
+
+func NewLivenessTracker(multiplier float64) *LivenessTracker {
+	return &LivenessTracker{
+		leases:     make(map[string]leaseObservation),
+		multiplier: multiplier,
+	}
+}
+
+// Observe records lease, it is meant to be fed every informer event including resyncs
+func (lt *LivenessTracker) Observe(lease *coordv1.Lease) {
+	if lease.Spec.RenewTime == nil {
+		return
+	}
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	o, ok := lt.leases[lease.Name]
+	switch {
+	case !ok:
+		// the renew time is on the node's clock, wait for a renewal we observe ourselves
+		o.observed = time.Now()
+	case !o.renewTime.Equal(lease.Spec.RenewTime.Time):
+		o.observed = time.Now()
+		o.renewed = true
+	}
+	o.renewTime = lease.Spec.RenewTime.Time
+	o.duration = leaseDuration(lease)
+	lt.leases[lease.Name] = o
+}
+
+// ObserveRenewal records that lease was renewed at time at, as told by a clock the caller
+// trusts, e.g. the API server's. Times in the future count as now.
+func (lt *LivenessTracker) ObserveRenewal(lease *coordv1.Lease, at time.Time) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	if now := time.Now(); at.After(now) {
+		at = now
+	}
+	o := leaseObservation{observed: at, renewed: true, duration: leaseDuration(lease)}
+	if lease.Spec.RenewTime != nil {
+		o.renewTime = lease.Spec.RenewTime.Time
+	}
+	lt.leases[lease.Name] = o
+}
+
+// leaseDuration returns how long lease is valid after a renewal
+func leaseDuration(lease *coordv1.Lease) time.Duration {
+	if lease.Spec.LeaseDurationSeconds != nil && *lease.Spec.LeaseDurationSeconds > 0 {
+		return time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	return defaultLeaseDuration
+}
+
+// Forget drops the observations of lease name, e.g. when it is deleted
+func (lt *LivenessTracker) Forget(name string) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	delete(lt.leases, name)
+}
+
+// Status returns the liveness of node name
+func (lt *LivenessTracker) Status(name string) Liveness {
+	lt.lock.RLock()
+	o, ok := lt.leases[name]
+	lt.lock.RUnlock()
+	if !ok {
+		return LivenessUnknown
+	}
+	if time.Now().After(lt.expiry(o)) {
+		return LivenessDead
+	}
+	if !o.renewed {
+		return LivenessUnknown
+	}
+	return LivenessAlive
+}
+
+// Expiry returns when node name is, or was, considered dead without a further renewal,
+// i.e. when its heartbeat is lost. It returns false if no lease of the node is observed.
+func (lt *LivenessTracker) Expiry(name string) (time.Time, bool) {
+	lt.lock.RLock()
+	defer lt.lock.RUnlock()
+
+	o, ok := lt.leases[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return lt.expiry(o), true
+}
+
+func (lt *LivenessTracker) expiry(o leaseObservation) time.Time {
+	return o.observed.Add(time.Duration(float64(o.duration) * lt.multiplier))
+}
+
+// ServerRenewTime returns when the API server last saw lease written, on its own clock, as
+// recorded in the managed fields of the lease. It returns false if there are none.
+func ServerRenewTime(lease *coordv1.Lease) (time.Time, bool) {
+	var last time.Time
+	for _, f := range lease.ManagedFields {
+		if f.Time != nil && f.Time.After(last) {
+			last = f.Time.Time
+		}
+	}
+	return last, !last.IsZero()
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func lease(name string, renewed time.Time, seconds int32) *coordv1.Lease {
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: coordv1.LeaseSpec{
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &metav1.MicroTime{Time: renewed},
+		},
+	}
+}
+
+func TestLivenessTracker(t *testing.T) {
+	lt := NewLivenessTracker(2)
+	now := time.Now()
+
+	// first seen, e.g. after a restart, whatever the node's clock tells
+	lt.Observe(lease("fresh", now, 10))
+	lt.Observe(lease("stale", now.Add(-time.Hour), 10))
+	lt.Observe(lease("ahead", now.Add(time.Hour), 10))
+	// clock far behind, but a renewal we observe ourselves is alive
+	lt.Observe(lease("behind", now.Add(-time.Hour), 10))
+	lt.Observe(lease("behind", now.Add(-time.Hour+10*time.Second), 10))
+	// renewals told by a trusted clock, 15s and 30s ago
+	lt.ObserveRenewal(lease("late", now, 10), now.Add(-15*time.Second))
+	lt.ObserveRenewal(lease("dead", now, 10), now.Add(-30*time.Second))
+	// first seen one timeout ago, never renewed since
+	lt.Observe(lease("lost", now.Add(-time.Hour), 10))
+	o := lt.leases["lost"]
+	o.observed = now.Add(-21 * time.Second)
+	lt.leases["lost"] = o
+
+	tests := []struct {
+		name   string
+		expect Liveness
+	}{
+		{"fresh", LivenessUnknown},
+		{"stale", LivenessUnknown},
+		{"ahead", LivenessUnknown},
+		{"behind", LivenessAlive},
+		{"late", LivenessAlive},
+		{"dead", LivenessDead},
+		{"lost", LivenessDead},
+		{"missing", LivenessUnknown},
+	}
+	for _, tt := range tests {
+		if got := lt.Status(tt.name); got != tt.expect {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.expect, got)
+		}
+	}
+
+	// resyncs of the same lease are no renewals
+	lt.Observe(lease("stale", now.Add(-time.Hour), 10))
+	if got := lt.Status("stale"); got != LivenessUnknown {
+		t.Errorf("expect %v, but %v returned", LivenessUnknown, got)
+	}
+	lt.Observe(lease("dead", now, 10))
+	if got := lt.Status("dead"); got != LivenessDead {
+		t.Errorf("expect %v, but %v returned", LivenessDead, got)
+	}
+
+	lt.Forget("behind")
+	if got := lt.Status("behind"); got != LivenessUnknown {
+		t.Errorf("expect %v, but %v returned", LivenessUnknown, got)
+	}
+}
@@ -1,109 +1,113 @@
 package utils
 
 import (
-	"sync"
+	"fmt"
 	"time"
 
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/sets"
-	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/klog/v2"
 )
 
-type NodepoolMap struct {
-	nodepools map[string]sets.String
-	lock      sync.Mutex
+func NodeIsInAutonomy(node *corev1.Node) bool {
+	_, ok := NodeAutonomyDuration(node)
+	return ok
 }
 
-func NewNodepoolMap() *NodepoolMap {
-	return &NodepoolMap{
-		nodepools: make(map[string]sets.String),
-	}
+// NodeAutonomyDuration returns how long node stays in autonomy once its heartbeat is lost,
+// zero meaning without limit, and false if node is not in autonomy
+func NodeAutonomyDuration(node *corev1.Node) (time.Duration, bool) {
+	return nodeAutonomyDuration(node.Annotations)
 }
 
-func (m *NodepoolMap) Add(pool, node string) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	if m.nodepools[pool] == nil {
-		m.nodepools[pool] = sets.String{}
+// nodeAutonomyDuration reads the autonomy of a node from its annotations, the newer
+// autonomy-duration takes precedence over the beta autonomy annotation
+func nodeAutonomyDuration(annotations map[string]string) (time.Duration, bool) {
+	if d, ok := autonomyDuration(annotations, constant.AnnotationKeyNodeAutonomyDuration); ok {
+		return d, true
 	}
-	m.nodepools[pool].Insert(node)
+	return 0, annotations[constant.AnnotationKeyNodeAutonomy] == "true"
 }
 
-func (m *NodepoolMap) Del(pool, node string) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
+// PodAutonomyDuration returns how long pod stays protected once the heartbeat of its node
+// is lost, zero meaning without limit, and false if pod does not tell
+func PodAutonomyDuration(pod *corev1.Pod) (time.Duration, bool) {
+	return autonomyDuration(pod.Annotations, constant.PodAutonomyDurationAnnotation)
+}
 
-	if m.nodepools[pool] == nil {
-		return
+// autonomyDuration parses annotation key of annotations. An invalid value means autonomy
+// without limit, as protecting too long is safer than evicting too early.
+func autonomyDuration(annotations map[string]string, key string) (time.Duration, bool) {
+	v, ok := annotations[key]
+	if !ok {
+		return 0, false
 	}
-
-	m.nodepools[pool].Delete(node)
-	if m.nodepools[pool].Len() == 0 {
-		delete(m.nodepools, pool)
+	if v == "0" {
+		return 0, true
 	}
-}
-
-func (m *NodepoolMap) Count(pool string) int {
-	if m.nodepools[pool] != nil {
-		return m.nodepools[pool].Len()
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		klog.Warningf("invalid %s=%q, autonomy is without limit", key, v)
+		return 0, true
 	}
-	return 0
+	return d, true
 }
 
-func (m *NodepoolMap) Nodes(pool string) []string {
-	if m.nodepools[pool] != nil {
-		return m.nodepools[pool].UnsortedList()
+func NodeNodepool(node *corev1.Node) (string, bool) {
+	if node.Labels != nil {
+		val, ok := node.Labels[constant.LabelKeyNodePool]
+		return val, ok
 	}
-	return []string{}
-}
 
-func (m *NodepoolMap) Sync(nodes []*corev1.Node) {
-	for _, n := range nodes {
-		pool, ok := NodeNodepool(n)
-		if ok {
-			m.Add(pool, n.Name)
-		}
-	}
+	return "", false
 }
 
-func NodeIsInAutonomy(node *corev1.Node) bool {
-	if node.Annotations != nil && node.Annotations[constant.AnnotationKeyNodeAutonomy] == "true" {
-		return true
-	}
-	return false
+// NodePool is what we use of an apps.openyurt.io NodePool object
+type NodePool struct {
+	Name string
+	// Edge or Cloud
+	Type        string
+	Annotations map[string]string
+	Nodes       []string
+	// nodes of the pool are in autonomy, as if each was annotated
+	Autonomy bool
+	// how long nodes of the pool stay in autonomy once their heartbeat is lost, zero meaning without limit
+	AutonomyDuration time.Duration
 }
 
-func NodeIsAlive(leaseLister leaselisterv1.LeaseNamespaceLister, nodeName string) bool {
-	lease, err := leaseLister.Get(nodeName)
-	if err != nil {
-		klog.Error(err)
-		return false
+// ParseNodePool extracts a NodePool from an object of the NodePool informer
+func ParseNodePool(obj interface{}) (*NodePool, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected nodepool object %T", obj)
 	}
-	diff := time.Now().Sub(lease.Spec.RenewTime.Time)
-	if diff.Seconds() > 40 {
-		return false
+	np := &NodePool{
+		Name:        u.GetName(),
+		Annotations: u.GetAnnotations(),
 	}
-	return true
-}
-
-func CountAliveNode(leaseLister leaselisterv1.LeaseNamespaceLister, nodes []string) int {
-	cnt := 0
-	for _, n := range nodes {
-		if NodeIsAlive(leaseLister, n) {
-			cnt++
+	var err error
+	if np.Type, _, err = unstructured.NestedString(u.Object, "spec", "type"); err != nil {
+		return nil, err
+	}
+	if np.Nodes, _, err = unstructured.NestedStringSlice(u.Object, "status", "nodes"); err != nil {
+		return nil, err
+	}
+	// annotations the NodePool propagates to its nodes count as well
+	specAnnotations, _, err := unstructured.NestedStringMap(u.Object, "spec", "annotations")
+	if err != nil {
+		return nil, err
+	}
+	for _, annotations := range []map[string]string{np.Annotations, specAnnotations} {
+		if d, ok := nodeAutonomyDuration(annotations); ok {
+			np.Autonomy, np.AutonomyDuration = true, d
+			break
 		}
 	}
-	return cnt
+	return np, nil
 }
 
-func NodeNodepool(node *corev1.Node) (string, bool) {
-	if node.Labels != nil {
-		val, ok := node.Labels[constant.LabelKeyNodePool]
-		return val, ok
-	}
-
-	return "", false
+// IsCloud tells whether nodes of the pool run in the cloud, where autonomy does not apply
+func (np *NodePool) IsCloud() bool {
+	return np.Type == constant.NodePoolTypeCloud
 }
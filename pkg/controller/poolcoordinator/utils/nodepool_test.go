@@ -2,43 +2,69 @@ package utils
 
 import (
 	"reflect"
-	"sort"
 	"testing"
-)
+	"time"
 
-func TestNodeMap(t *testing.T) {
-	nm := NewNodepoolMap()
-	nm.Add("pool1", "node1")
-	nm.Add("pool1", "node2")
-	nm.Add("pool2", "node3")
-	nm.Add("pool2", "node4")
-	nm.Add("pool2", "node5")
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
 
-	if nm.Count("pool1") != 2 {
-		t.Errorf("expect %v, but %v returned", 2, nm.Count("pool1"))
-	}
-	if nm.Count("pool2") != 3 {
-		t.Errorf("expect %v, but %v returned", 3, nm.Count("pool2"))
-	}
-	nm.Del("pool2", "node4")
-	if nm.Count("pool2") != 2 {
-		t.Errorf("expect %v, but %v returned", 2, nm.Count("pool2"))
+func TestNodeAutonomyDuration(t *testing.T) {
+	tests := []struct {
+		annotations map[string]string
+		duration    time.Duration
+		autonomy    bool
+	}{
+		{nil, 0, false},
+		{map[string]string{constant.AnnotationKeyNodeAutonomy: "true"}, 0, true},
+		{map[string]string{constant.AnnotationKeyNodeAutonomy: "false"}, 0, false},
+		{map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "0"}, 0, true},
+		{map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "10m"}, 10 * time.Minute, true},
+		// an invalid duration is without limit
+		{map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "soon"}, 0, true},
+		// the newer annotation takes precedence
+		{map[string]string{
+			constant.AnnotationKeyNodeAutonomy:         "true",
+			constant.AnnotationKeyNodeAutonomyDuration: "1h",
+		}, time.Hour, true},
 	}
-	nodes := nm.Nodes("pool2")
-	sort.Sort(sort.StringSlice(nodes))
-	expected := []string{"node3", "node5"}
-	if !reflect.DeepEqual(nodes, expected) {
-		t.Errorf("expect %v, but %v returned", expected, nodes)
+	for _, tt := range tests {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+		d, ok := NodeAutonomyDuration(node)
+		if d != tt.duration || ok != tt.autonomy {
+			t.Errorf("%v: expect %v %v, but %v %v returned", tt.annotations, tt.duration, tt.autonomy, d, ok)
+		}
 	}
+}
+
+func TestParseNodePool(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.openyurt.io/v1beta1",
+		"kind":       "NodePool",
+		"metadata": map[string]interface{}{
+			"name": "hangzhou",
+		},
+		"spec": map[string]interface{}{
+			"type": "Edge",
+			"annotations": map[string]interface{}{
+				constant.AnnotationKeyNodeAutonomy: "true",
+			},
+		},
+		"status": map[string]interface{}{
+			"nodes": []interface{}{"node1", "node2"},
+		},
+	}}
 
-	nm.Del("pool1", "node1")
-	nm.Del("pool1", "node2")
-	if nm.Count("pool1") != 0 {
-		t.Errorf("expect %v, but %v returned", 0, nm.Count("pool1"))
+	np, err := ParseNodePool(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if np.Name != "hangzhou" || np.IsCloud() || !np.Autonomy {
+		t.Errorf("expect autonomous edge pool hangzhou, but %+v returned", np)
 	}
-	nodes = nm.Nodes("pool1")
-	expected = []string{}
-	if !reflect.DeepEqual(nodes, expected) {
-		t.Errorf("expect %v, but %v returned", expected, nodes)
+	if !reflect.DeepEqual(np.Nodes, []string{"node1", "node2"}) {
+		t.Errorf("expect %v, but %v returned", []string{"node1", "node2"}, np.Nodes)
 	}
 }
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// PoolState tells how well a pool is connected to the cloud
+type PoolState string
+
+const (
+	// few or no nodes of the pool have their heartbeat delegated
+	PoolConnected PoolState = "Connected"
+	// part of the pool lost its link to the cloud
+	PoolDegraded PoolState = "Degraded"
+	// most of the pool lost its link to the cloud
+	PoolPartitioned PoolState = "Partitioned"
+)
+
+type poolPartition struct {
+	state PoolState
+	// a state observed lately, and since when, not held for long enough to switch to yet
+	pending PoolState
+	since   time.Time
+}
+
+// PartitionDetector tracks the state of every pool from the share of its nodes whose
+// heartbeat is delegated. A pool only switches state once the new state held for hysteresis,
+// so a flapping link does not flip the pool back and forth.
+type PartitionDetector struct {
+	degradedRatio    float64
+	partitionedRatio float64
+	hysteresis       time.Duration
+	pools            map[string]*poolPartition
+	lock             sync.Mutex
+}
+
+func NewPartitionDetector(degradedRatio, partitionedRatio float64, hysteresis time.Duration) *PartitionDetector {
+	return &PartitionDetector{
+		degradedRatio:    degradedRatio,
+		partitionedRatio: partitionedRatio,
+		hysteresis:       hysteresis,
+		pools:            make(map[string]*poolPartition),
+	}
+}
+
+// stateOf returns the state stats of a pool point to right now
+func (d *PartitionDetector) stateOf(s PoolHealthStats) PoolState {
+	if s.Nodes == 0 {
+		return PoolConnected
+	}
+	ratio := float64(s.Delegated) / float64(s.Nodes)
+	switch {
+	case ratio >= d.partitionedRatio:
+		return PoolPartitioned
+	case ratio >= d.degradedRatio:
+		return PoolDegraded
+	}
+	return PoolConnected
+}
+
+// Observe records stats of pool seen at now, and returns the state of the pool and whether
+// it just changed. A pool observed for the first time takes the state right away.
+func (d *PartitionDetector) Observe(pool string, s PoolHealthStats, now time.Time) (PoolState, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	observed := d.stateOf(s)
+	p, ok := d.pools[pool]
+	if !ok {
+		d.pools[pool] = &poolPartition{state: observed}
+		return observed, false
+	}
+	if observed == p.state {
+		p.pending = ""
+		return p.state, false
+	}
+	if observed != p.pending {
+		p.pending, p.since = observed, now
+	}
+	if now.Sub(p.since) < d.hysteresis {
+		return p.state, false
+	}
+	p.state, p.pending = observed, ""
+	return p.state, true
+}
+
+// State returns the state of pool, Connected if it was never observed
+func (d *PartitionDetector) State(pool string) PoolState {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if p, ok := d.pools[pool]; ok {
+		return p.state
+	}
+	return PoolConnected
+}
+
+// Retain forgets every pool but pools
+func (d *PartitionDetector) Retain(pools []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	keep := make(map[string]bool, len(pools))
+	for _, pool := range pools {
+		keep[pool] = true
+	}
+	for pool := range d.pools {
+		if !keep[pool] {
+			delete(d.pools, pool)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionDetector(t *testing.T) {
+	d := NewPartitionDetector(0.2, 0.6, time.Minute)
+	start := time.Now()
+
+	tests := []struct {
+		name      string
+		delegated int
+		after     time.Duration
+		state     PoolState
+		changed   bool
+	}{
+		{"first observation is taken right away", 0, 0, PoolConnected, false},
+		{"partition is held back", 8, 10 * time.Second, PoolConnected, false},
+		{"flap resets hysteresis", 0, 20 * time.Second, PoolConnected, false},
+		{"partition starts again", 8, 30 * time.Second, PoolConnected, false},
+		{"partition held long enough", 8, 90 * time.Second, PoolPartitioned, true},
+		{"partition stays", 7, 100 * time.Second, PoolPartitioned, false},
+		{"degraded is held back", 3, 110 * time.Second, PoolPartitioned, false},
+		{"degraded held long enough", 3, 170 * time.Second, PoolDegraded, true},
+	}
+	for _, tt := range tests {
+		state, changed := d.Observe("pool1", PoolHealthStats{Nodes: 10, Delegated: tt.delegated}, start.Add(tt.after))
+		if state != tt.state || changed != tt.changed {
+			t.Errorf("%s: expect %v %v, but %v %v returned", tt.name, tt.state, tt.changed, state, changed)
+		}
+	}
+
+	d.Retain(nil)
+	if state := d.State("pool1"); state != PoolConnected {
+		t.Errorf("expect %v, but %v returned", PoolConnected, state)
+	}
+}
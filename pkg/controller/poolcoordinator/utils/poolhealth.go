@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+)
+
+// PoolHealthStats counts the nodes of a pool
+type PoolHealthStats struct {
+	Nodes     int
+	Alive     int
+	Delegated int
+}
+
+type nodeHealth struct {
+	pool      string
+	alive     bool
+	delegated bool
+}
+
+// PoolHealth keeps per-pool counts of alive and delegated nodes, updated node by node as
+// leases and nodes change, so they are read in constant time
+type PoolHealth struct {
+	nodes map[string]nodeHealth
+	pools map[string]*PoolHealthStats
+	lock  sync.RWMutex
+}
+
+func NewPoolHealth() *PoolHealth {
+	return &PoolHealth{
+		nodes: make(map[string]nodeHealth),
+		pools: make(map[string]*PoolHealthStats),
+	}
+}
+
+// Set records the pool and health of node
+func (h *PoolHealth) Set(node, pool string, alive, delegated bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	n := nodeHealth{pool: pool, alive: alive, delegated: delegated}
+	if old, ok := h.nodes[node]; ok {
+		if old == n {
+			return
+		}
+		h.count(old, -1)
+	}
+	h.nodes[node] = n
+	h.count(n, 1)
+}
+
+// Delete forgets node
+func (h *PoolHealth) Delete(node string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if old, ok := h.nodes[node]; ok {
+		h.count(old, -1)
+		delete(h.nodes, node)
+	}
+}
+
+func (h *PoolHealth) count(n nodeHealth, delta int) {
+	s := h.pools[n.pool]
+	if s == nil {
+		s = &PoolHealthStats{}
+		h.pools[n.pool] = s
+	}
+	s.Nodes += delta
+	if n.alive {
+		s.Alive += delta
+	}
+	if n.delegated {
+		s.Delegated += delta
+	}
+	if s.Nodes == 0 {
+		delete(h.pools, n.pool)
+	}
+}
+
+// Stats returns the counts of pool
+func (h *PoolHealth) Stats(pool string) PoolHealthStats {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if s := h.pools[pool]; s != nil {
+		return *s
+	}
+	return PoolHealthStats{}
+}
+
+// Pools returns the pools having nodes
+func (h *PoolHealth) Pools() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	pools := make([]string, 0, len(h.pools))
+	for pool := range h.pools {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	return pools
+}
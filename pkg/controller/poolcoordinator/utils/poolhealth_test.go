@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPoolHealth(t *testing.T) {
+	h := NewPoolHealth()
+	h.Set("node1", "pool1", true, false)
+	h.Set("node2", "pool1", false, true)
+	h.Set("node3", "pool2", true, true)
+	// unchanged health is not counted twice
+	h.Set("node1", "pool1", true, false)
+
+	if got := h.Stats("pool1"); got != (PoolHealthStats{Nodes: 2, Alive: 1, Delegated: 1}) {
+		t.Errorf("expect 2 nodes, 1 alive, 1 delegated, but %+v returned", got)
+	}
+
+	// node2 moves to pool2 and recovers
+	h.Set("node2", "pool2", true, false)
+	if got := h.Stats("pool2"); got != (PoolHealthStats{Nodes: 2, Alive: 2, Delegated: 1}) {
+		t.Errorf("expect 2 nodes, 2 alive, 1 delegated, but %+v returned", got)
+	}
+
+	h.Delete("node1")
+	if !reflect.DeepEqual(h.Pools(), []string{"pool2"}) {
+		t.Errorf("expect %v, but %v returned", []string{"pool2"}, h.Pools())
+	}
+	if got := h.Stats("pool1"); got != (PoolHealthStats{}) {
+		t.Errorf("expect empty stats, but %+v returned", got)
+	}
+}
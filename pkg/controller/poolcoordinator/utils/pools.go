@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// nodes by their pool label
+	NodePoolIndex = "nodepool"
+	// nodes carrying quorum annotations by their pool label
+	NodePoolQuorumIndex = "nodepool-quorum"
+	// NodePools by their member nodes
+	NodePoolMemberIndex = "member"
+)
+
+// QuorumAnnotations override the quorum of a pool, on a NodePool or on nodes of the pool
+var QuorumAnnotations = []string{
+	constant.AnnotationKeyMinNodes,
+	constant.AnnotationKeyMinAliveRatio,
+	constant.AnnotationKeyMinAliveNodes,
+}
+
+// NodeIndexers are the indexes Pools needs on the node informer
+func NodeIndexers() cache.Indexers {
+	return cache.Indexers{
+		NodePoolIndex:       nodePoolIndexFunc,
+		NodePoolQuorumIndex: nodePoolQuorumIndexFunc,
+	}
+}
+
+// NodePoolIndexers are the indexes Pools needs on the NodePool informer
+func NodePoolIndexers() cache.Indexers {
+	return cache.Indexers{
+		NodePoolMemberIndex: nodePoolMemberIndexFunc,
+	}
+}
+
+func nodePoolIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return nil, nil
+	}
+	if pool, ok := NodeNodepool(node); ok {
+		return []string{pool}, nil
+	}
+	return nil, nil
+}
+
+func nodePoolQuorumIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return nil, nil
+	}
+	for _, key := range QuorumAnnotations {
+		if _, ok := node.Annotations[key]; ok {
+			return nodePoolIndexFunc(obj)
+		}
+	}
+	return nil, nil
+}
+
+func nodePoolMemberIndexFunc(obj interface{}) ([]string, error) {
+	np, err := ParseNodePool(obj)
+	if err != nil {
+		return nil, nil
+	}
+	return np.Nodes, nil
+}
+
+// Pools serves pool membership from informer indexes. Membership comes from NodePool
+// objects if they are served, otherwise from the pool label of nodes.
+type Pools struct {
+	nodes cache.Indexer
+	// nil if NodePools are not served
+	nodepools cache.Indexer
+}
+
+func NewPools(nodes cache.Indexer, nodepools cache.Indexer) *Pools {
+	return &Pools{
+		nodes:     nodes,
+		nodepools: nodepools,
+	}
+}
+
+// NodePool returns NodePool pool, or nil if NodePools are not served or it does not exist
+func (p *Pools) NodePool(pool string) *NodePool {
+	if p.nodepools == nil || pool == "" {
+		return nil
+	}
+	obj, ok, err := p.nodepools.GetByKey(pool)
+	if err != nil || !ok {
+		return nil
+	}
+	np, err := ParseNodePool(obj)
+	if err != nil {
+		klog.Warningf("could not parse nodepool %s: %v", pool, err)
+		return nil
+	}
+	return np
+}
+
+// Nodes returns the names of nodes in pool
+func (p *Pools) Nodes(pool string) []string {
+	if np := p.NodePool(pool); np != nil {
+		return np.Nodes
+	}
+	keys, err := p.nodes.IndexKeys(NodePoolIndex, pool)
+	if err != nil {
+		klog.Error(err)
+		return []string{}
+	}
+	return keys
+}
+
+// Pool returns the pool of node
+func (p *Pools) Pool(node *corev1.Node) (string, bool) {
+	if p.nodepools != nil {
+		keys, err := p.nodepools.IndexKeys(NodePoolMemberIndex, node.Name)
+		if err == nil && len(keys) > 0 {
+			return keys[0], true
+		}
+	}
+	return NodeNodepool(node)
+}
+
+// AutonomyDuration returns how long node stays in autonomy once its heartbeat is lost, by
+// its own annotations or its NodePool's, zero meaning without limit. Autonomy does not
+// apply to nodes of a Cloud NodePool.
+func (p *Pools) AutonomyDuration(node *corev1.Node) (time.Duration, bool) {
+	pool, _ := p.Pool(node)
+	np := p.NodePool(pool)
+	if np != nil && np.IsCloud() {
+		return 0, false
+	}
+	if d, ok := NodeAutonomyDuration(node); ok {
+		return d, true
+	}
+	if np != nil && np.Autonomy {
+		return np.AutonomyDuration, true
+	}
+	return 0, false
+}
+
+// Pools returns the names of all pools
+func (p *Pools) Pools() []string {
+	pools := sets.NewString(p.nodes.ListIndexFuncValues(NodePoolIndex)...)
+	if p.nodepools != nil {
+		pools.Insert(p.nodepools.ListKeys()...)
+	}
+	return pools.List()
+}
+
+// QuorumNodes returns the nodes of pool carrying quorum annotations
+func (p *Pools) QuorumNodes(pool string) []*corev1.Node {
+	objs, err := p.nodes.ByIndex(NodePoolQuorumIndex, pool)
+	if err != nil {
+		klog.Error(err)
+		return nil
+	}
+	nodes := make([]*corev1.Node, 0, len(objs))
+	for _, obj := range objs {
+		if node, ok := obj.(*corev1.Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// pods by the node they are bound to
+const PodNodeNameIndex = "nodeName"
+
+// PodIndexers are the indexes the capacity check needs on the pod informer
+func PodIndexers() cache.Indexers {
+	return cache.Indexers{
+		PodNodeNameIndex: func(obj interface{}) ([]string, error) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || pod.Spec.NodeName == "" {
+				return nil, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	}
+}
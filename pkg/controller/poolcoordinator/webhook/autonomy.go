@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+)
+
+// autonomyDuration returns how long the pod stays protected once the heartbeat of its node is
+// lost, zero meaning without limit, and false if neither the pod nor its node is in autonomy.
+// The pod's own annotation takes precedence over its node's.
+func (pv *PodAdmission) autonomyDuration() (time.Duration, bool) {
+	if d, ok := utils.PodAutonomyDuration(pv.pod); ok {
+		return d, true
+	}
+	return pools.AutonomyDuration(pv.node)
+}
+
+// autonomyExpired tells whether the heartbeat of node name has been lost for longer than d
+func autonomyExpired(name string, d time.Duration) bool {
+	if d == 0 || liveness.Status(name) != utils.LivenessDead {
+		return false
+	}
+	expiry, ok := liveness.Expiry(name)
+	return ok && time.Since(expiry) > d
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// fitsInPool tells whether a replacement of the pod fits on an alive node of pool other than
+// its own, honoring taints, nodeSelector and the requests of pods already bound to each node
+func (pv *PodAdmission) fitsInPool(pool string) bool {
+	if podIndexer == nil {
+		return true
+	}
+	requests := podRequests(pv.pod)
+	for _, name := range pools.Nodes(pool) {
+		if name == pv.node.Name || liveness.Status(name) != utils.LivenessAlive {
+			continue
+		}
+		node, err := nodeLister.Get(name)
+		if err != nil {
+			continue
+		}
+		if pv.schedulableOn(node) && fits(requests, node, nodeRequests(name)) {
+			klog.Infof("pod %s/%s fits on node %s of pool %s", pv.pod.Namespace, pv.pod.Name, name, pool)
+			return true
+		}
+	}
+	return false
+}
+
+// schedulableOn tells whether node admits the pod by its taints and the pod's nodeSelector
+func (pv *PodAdmission) schedulableOn(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	if !labels.SelectorFromSet(pv.pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !tolerates(pv.pod.Spec.Tolerations, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+func tolerates(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// podRequests returns the resources the pod requests, as the scheduler computes them:
+// the sum of its containers or the largest init container, whichever is more, plus overhead.
+// The pod itself counts as one of the node's pods.
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	reqs := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(reqs, c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if cur, ok := reqs[name]; !ok || q.Cmp(cur) > 0 {
+				reqs[name] = q.DeepCopy()
+			}
+		}
+	}
+	addResources(reqs, pod.Spec.Overhead)
+	addResources(reqs, corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)})
+	return reqs
+}
+
+// nodeRequests returns the resources requested by pods bound to node name
+func nodeRequests(name string) corev1.ResourceList {
+	used := corev1.ResourceList{}
+	objs, err := podIndexer.ByIndex(utils.PodNodeNameIndex, name)
+	if err != nil {
+		klog.Error(err)
+		return used
+	}
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		addResources(used, podRequests(pod))
+	}
+	return used
+}
+
+func addResources(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		cur := dst[name]
+		cur.Add(q)
+		dst[name] = cur
+	}
+}
+
+// fits tells whether requests fit into the allocatable resources of node left by used
+func fits(requests corev1.ResourceList, node *corev1.Node, used corev1.ResourceList) bool {
+	for name, q := range requests {
+		if q.IsZero() {
+			continue
+		}
+		free := node.Status.Allocatable[name]
+		free.Sub(used[name])
+		if q.Cmp(free) > 0 {
+			return false
+		}
+	}
+	return true
+}
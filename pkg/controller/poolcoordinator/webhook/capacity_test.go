@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func capacityNode(name, cpu string) *corev1.Node {
+	node := poolNode(name, "pool1", nil)
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:  resource.MustParse(cpu),
+		corev1.ResourcePods: resource.MustParse("110"),
+	}
+	return node
+}
+
+func capacityPod(name, node, cpu string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{constant.PodAvailableAnnotation: constant.PodAvailablePool},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				},
+			}},
+		},
+	}
+}
+
+func TestValidatePoolCapacity(t *testing.T) {
+	cfg = config.Default()
+	tainted := capacityNode("node3", "4")
+	tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+
+	tests := []struct {
+		name    string
+		nodes   []*corev1.Node
+		pods    []*corev1.Pod
+		allowed bool
+	}{
+		{"free capacity", []*corev1.Node{capacityNode("node1", "4"), capacityNode("node2", "4"), capacityNode("node3", "4")},
+			[]*corev1.Pod{capacityPod("pod1", "node1", "2"), capacityPod("pod2", "node2", "1")}, true},
+		{"full nodes", []*corev1.Node{capacityNode("node1", "4"), capacityNode("node2", "2"), capacityNode("node3", "2")},
+			[]*corev1.Pod{capacityPod("pod1", "node1", "2"), capacityPod("pod2", "node2", "1"), capacityPod("pod3", "node3", "1")}, false},
+		{"untolerated taint", []*corev1.Node{capacityNode("node1", "4"), capacityNode("node2", "2"), tainted},
+			[]*corev1.Pod{capacityPod("pod1", "node1", "2"), capacityPod("pod2", "node2", "1")}, false},
+	}
+	for _, tt := range tests {
+		setupListers(t, tt.nodes, tt.pods)
+		podIndexer = cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.PodIndexers())
+		for _, p := range tt.pods {
+			if err := podIndexer.Add(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		observeLease("node1", time.Now().Add(-time.Minute))
+		observeLease("node2", time.Now())
+		observeLease("node3", time.Now())
+		syncHealth(t)
+
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", "pod1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+		if !tt.allowed && out.Response.Result.Message != msgPoolHasNoCapacity {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, msgPoolHasNoCapacity, out.Response.Result.Message)
+		}
+	}
+}
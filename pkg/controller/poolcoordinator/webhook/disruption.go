@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+// checkDisruptionBudgets returns a denial reason if deleting the pod from pool would leave
+// one of its PodDisruptionBudgets short. Only pods on alive nodes of the same pool are
+// counted as available, so a partition cannot take down every replica at once.
+func (pv *PodAdmission) checkDisruptionBudgets(pool string) (string, error) {
+	if pdbLister == nil {
+		return "", nil
+	}
+	pdbs, err := pdbLister.PodDisruptionBudgets(pv.pod.Namespace).List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	for _, pdb := range pdbs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		// a nil selector matches no pods
+		if err != nil || pdb.Spec.Selector == nil || !selector.Matches(labels.Set(pv.pod.Labels)) {
+			continue
+		}
+		pods, err := podLister.Pods(pv.pod.Namespace).List(selector)
+		if err != nil {
+			return "", err
+		}
+
+		expected, available := 0, 0
+		for _, p := range pods {
+			if p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			expected++
+			if p.UID != pv.pod.UID && pv.availableInPool(p, pool) {
+				available++
+			}
+		}
+		desired, ok := desiredHealthy(pdb, expected)
+		if ok && available < desired {
+			klog.Infof("deleting pod %s/%s violates pdb %s, %d pods available in pool %s, %d desired",
+				pv.pod.Namespace, pv.pod.Name, pdb.Name, available, pool, desired)
+			return msgPDBViolated, nil
+		}
+	}
+	return "", nil
+}
+
+// availableInPool tells whether pod is ready on an alive node of pool
+func (pv *PodAdmission) availableInPool(pod *corev1.Pod, pool string) bool {
+	if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil || !isPodReady(pod) {
+		return false
+	}
+	if liveness.Status(pod.Spec.NodeName) != utils.LivenessAlive {
+		return false
+	}
+	node, err := nodeLister.Get(pod.Spec.NodeName)
+	if err != nil {
+		return false
+	}
+	p, ok := nodePool(node)
+	return ok && p == pool
+}
+
+// isPodReady tells whether pod is ready, as the disruption controller counts healthy pods
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// desiredHealthy returns how many of expected pods pdb wants available. Percentages are
+// resolved against the number of matching pods, rounding up as the disruption controller does.
+func desiredHealthy(pdb *policyv1.PodDisruptionBudget, expected int) (int, bool) {
+	switch {
+	case pdb.Spec.MinAvailable != nil:
+		v, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, expected, true)
+		if err != nil {
+			klog.Warningf("invalid minAvailable of pdb %s/%s: %v", pdb.Namespace, pdb.Name, err)
+			return 0, false
+		}
+		return v, true
+	case pdb.Spec.MaxUnavailable != nil:
+		v, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, expected, true)
+		if err != nil {
+			klog.Warningf("invalid maxUnavailable of pdb %s/%s: %v", pdb.Namespace, pdb.Name, err)
+			return 0, false
+		}
+		return expected - v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	policylisterv1 "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestValidateDisruptionBudgets(t *testing.T) {
+	cfg = config.Default()
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2", "node3", "node4"} {
+		nodes = append(nodes, poolNode(name, "pool1", nil))
+	}
+	pods := []*corev1.Pod{}
+	for i, node := range []string{"node1", "node2", "node3"} {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "web" + string(rune('1'+i)),
+				Namespace:   "default",
+				UID:         types.UID(node),
+				Labels:      map[string]string{"app": "web"},
+				Annotations: map[string]string{constant.PodAvailableAnnotation: constant.PodAvailablePool},
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		})
+	}
+
+	minAvailable2 := intstr.FromInt(2)
+	minAvailable3 := intstr.FromInt(3)
+	maxUnavailable := intstr.FromString("50%")
+	tests := []struct {
+		name     string
+		spec     policyv1.PodDisruptionBudgetSpec
+		notReady bool
+		allowed  bool
+	}{
+		{"minAvailable kept", policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable2}, false, true},
+		{"minAvailable breached", policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable3}, false, false},
+		{"maxUnavailable kept", policyv1.PodDisruptionBudgetSpec{MaxUnavailable: &maxUnavailable}, false, true},
+		// web3 runs on an alive node, but is not ready
+		{"minAvailable breached by not ready pod", policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable2}, true, false},
+	}
+	for _, tt := range tests {
+		objs := pods
+		if tt.notReady {
+			notReady := pods[2].DeepCopy()
+			notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+			objs = []*corev1.Pod{pods[0], pods[1], notReady}
+		}
+		setupListers(t, nodes, objs)
+		// node1 with the evicted pod and node4 are dead
+		observeLease("node1", time.Now().Add(-time.Minute))
+		observeLease("node2", time.Now())
+		observeLease("node3", time.Now())
+		observeLease("node4", time.Now().Add(-time.Minute))
+		syncHealth(t)
+
+		pdb := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       tt.spec,
+		}
+		pdb.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		if err := indexer.Add(pdb); err != nil {
+			t.Fatal(err)
+		}
+		pdbLister = policylisterv1.NewPodDisruptionBudgetLister(indexer)
+
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", "web1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+		if !tt.allowed && out.Response.Result.Message != msgPDBViolated {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, msgPDBViolated, out.Response.Result.Message)
+		}
+	}
+}
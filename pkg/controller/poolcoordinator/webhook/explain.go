@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Explain tells whether an eviction of pod requested by user would be allowed right now, and
+// why, deciding as the validating webhook does on the state given to Setup. In dry-run mode
+// a denial is reported, but the eviction allowed.
+func Explain(pod *corev1.Pod, user authenticationv1.UserInfo) (bool, string, error) {
+	pv := &PodAdmission{
+		request: &admissionv1.AdmissionRequest{
+			Kind:        metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+			Resource:    metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			SubResource: "eviction",
+			Name:        pod.Name,
+			Namespace:   pod.Namespace,
+			Operation:   admissionv1.Create,
+			UserInfo:    user,
+		},
+		pod: pod,
+	}
+	policy := pv.evictionPolicy()
+	if policy == config.EvictionPolicyAllow {
+		return true, msgPodDeleteValidated, nil
+	}
+	bound, err := pv.getBoundNode()
+	if err != nil {
+		return false, "", fmt.Errorf("could not get node %s of pod: %v", pod.Spec.NodeName, err)
+	}
+	if !bound {
+		return true, msgPodNotBound, nil
+	}
+	val, err := pv.validateDel(policy)
+	if err != nil {
+		return false, "", err
+	}
+	if !val.Valid && pv.dryRun() {
+		return true, "dry-run, would be denied: " + val.Reason, nil
+	}
+	return val.Valid, val.Reason, nil
+}
@@ -0,0 +1,23 @@
+package webhook
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodePool returns the pool of node
+func nodePool(node *corev1.Node) (string, bool) {
+	return pools.Pool(node)
+}
+
+// nodeIsCloud tells whether node belongs to a Cloud NodePool
+func nodeIsCloud(node *corev1.Node) bool {
+	pool, _ := nodePool(node)
+	np := pools.NodePool(pool)
+	return np != nil && np.IsCloud()
+}
+
+// nodeInAutonomy tells whether node is in autonomy by its own annotation or its NodePool's
+func nodeInAutonomy(node *corev1.Node) bool {
+	_, ok := pools.AutonomyDuration(node)
+	return ok
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func nodePoolObject(name, poolType string, annotations map[string]string, nodes ...string) *unstructured.Unstructured {
+	members := []interface{}{}
+	for _, n := range nodes {
+		members = append(members, n)
+	}
+	np := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec":   map[string]interface{}{"type": poolType},
+		"status": map[string]interface{}{"nodes": members},
+	}}
+	np.SetAPIVersion("apps.openyurt.io/v1beta1")
+	np.SetKind("NodePool")
+	np.SetName(name)
+	np.SetAnnotations(annotations)
+	return np
+}
+
+func TestValidateNodePools(t *testing.T) {
+	cfg = config.Default()
+	// nodes carry no pool labels, membership comes from the NodePools
+	nodes := []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "edge1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "cloud1", Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"}}},
+	}
+	pods := []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "edge1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "cloud1"}},
+	}
+	nodeIndexer := setupListers(t, nodes, pods)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodePoolIndexers())
+	for _, np := range []*unstructured.Unstructured{
+		nodePoolObject("edge", constant.NodePoolTypeEdge, map[string]string{constant.AnnotationKeyNodeAutonomy: "true"}, "edge1"),
+		nodePoolObject("cloud", constant.NodePoolTypeCloud, nil, "cloud1"),
+	} {
+		if err := indexer.Add(np); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pools = utils.NewPools(nodeIndexer, indexer)
+
+	tests := []struct {
+		pod     string
+		allowed bool
+		reason  string
+	}{
+		// autonomy of the pool applies to its nodes
+		{"pod1", false, msgNodeAutonomy},
+		// cloud pools are not guarded
+		{"pod2", true, msgNodeInCloudPool},
+	}
+	for _, tt := range tests {
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", tt.pod),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.pod, tt.allowed, out.Response.Allowed)
+		}
+		if out.Response.Result.Message != tt.reason {
+			t.Errorf("%s: expect %v, but %v returned", tt.pod, tt.reason, out.Response.Result.Message)
+		}
+		if pv.pool() == "" {
+			t.Errorf("%s: expect pool from NodePool membership", tt.pod)
+		}
+	}
+}
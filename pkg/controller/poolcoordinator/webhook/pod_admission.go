@@ -2,49 +2,87 @@ package webhook
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/certs"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/client"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
-	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/lister"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/metrics"
 	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wI2L/jsondiff"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
-	leaselisterv1 "k8s.io/client-go/listers/coordination/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	listerv1 "k8s.io/client-go/listers/core/v1"
+	policylisterv1 "k8s.io/client-go/listers/policy/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 )
 
 const (
+	// reason of the events on pods whose deletion is denied
+	eventReasonEvictionDenied = "PoolCoordinatorEvictionDenied"
+
 	msgNodeAutonomy                      string = "node autonomy annotated, eviction aborted"
+	msgAutonomyExpired                   string = "autonomy expired since node heartbeat was lost, eviction approved"
 	msgPodAvailableNode                  string = "pod should exist on the specific node, eviction aborted"
 	msgPodAvailablePoolAndNodeIsAlive    string = "node is actually alive in a pool, eviction aborted"
 	msgPodAvailablePoolAndNodeIsNotAlive string = "node is not alive in a pool, eviction approved"
+	msgNodeLivenessUnknown               string = "node liveness is unknown, eviction aborted"
+	msgPDBViolated                       string = "pod disruption budget would be violated in the pool, eviction aborted"
+	msgIdentityDenied                    string = "deletion of protected pods is denied for this user, eviction aborted"
+	msgPoolHasNoCapacity                 string = "no alive node in the pool can fit the pod, eviction aborted"
+	msgNodeInCloudPool                   string = "node is in a cloud pool, eviction approved"
+	msgEvictionThrottled                 string = "nodepool evicts too fast, eviction throttled, retry later"
 	msgPodDeleteValidated                string = "pod deletion validated"
-	msgPoolHasTooFewNodes                string = "nodepool has too few nodes"
-	msgPoolHasTooFewReadyNodes           string = "nodepool has too few ready nodes"
+	msgPodNotBound                       string = "pod is not bound to an existing node, eviction approved"
+	msgPoolHasTooFewNodes                string = "nodepool has fewer nodes than minNodes, eviction aborted"
+	msgPoolHasTooFewAliveNodes           string = "nodepool has fewer alive nodes than minAliveNodes, eviction aborted"
+	msgPoolAliveRatioTooLow              string = "nodepool alive node ratio is below minAliveRatio, eviction aborted"
+
+	msgNoNeedOfMutation          string = "no need of mutation"
+	msgCouldNotMergeTolerations  string = "could not merge tolerations"
+	msgTolerationsAlreadyExisted string = "tolerations already existed"
+	msgTolerationsAdded          string = "tolerations added"
+	msgBadRequest                string = "bad request"
 )
 
 var (
 	ValidatePath string = "/pool-coordinator-webhook-validate"
 	MutatePath   string = "/pool-coordinator-webhook-mutate"
 	HealthPath   string = "/pool-coordinator-webhook-health"
-
-	nodeLister  listerv1.NodeLister
-	leaseLister leaselisterv1.LeaseNamespaceLister
-	nodepoolMap *utils.NodepoolMap
+	MetricsPath  string = "/metrics"
+
+	cfg        *config.Config = config.Default()
+	nodeLister listerv1.NodeLister
+	podLister  listerv1.PodLister
+	pdbLister  policylisterv1.PodDisruptionBudgetLister
+	// pods indexed by node name
+	podIndexer cache.Indexer
+	liveness   *utils.LivenessTracker
+	pools      *utils.Pools
+	health     *utils.PoolHealth
+	recorder   record.EventRecorder
 )
 
 type validation struct {
 	Valid  bool
 	Reason string
+	// set if the deletion is denied for now only, clients should retry after that many seconds
+	RetryAfterSeconds int32
 }
 
 type PodAdmission struct {
@@ -63,8 +101,74 @@ func (pv *PodAdmission) getPod() error {
 	return nil
 }
 
-func (pv *PodAdmission) userIsNodeController() bool {
-	return strings.Contains(pv.request.UserInfo.Username, "system:serviceaccount:kube-system:node-controller")
+// extracts the pod being created or updated from admission request
+func (pv *PodAdmission) getNewPod() error {
+	if err := json.Unmarshal(pv.request.Object.Raw, pv.pod); err != nil {
+		klog.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// extracts the pod targeted by an eviction from admission request
+func (pv *PodAdmission) getEvictedPod() error {
+	eviction := &policyv1.Eviction{}
+	if err := json.Unmarshal(pv.request.Object.Raw, eviction); err != nil {
+		klog.Error(err)
+		return err
+	}
+
+	name, namespace := eviction.Name, eviction.Namespace
+	if name == "" {
+		name = pv.request.Name
+	}
+	if namespace == "" {
+		namespace = pv.request.Namespace
+	}
+	pod, err := podLister.Pods(namespace).Get(name)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	pv.pod = pod
+
+	return nil
+}
+
+// isEviction returns true if the request is a CREATE on pods/eviction subresource
+func (pv *PodAdmission) isEviction() bool {
+	return pv.request.Kind.Kind == "Eviction" &&
+		pv.request.SubResource == "eviction" &&
+		pv.request.Operation == admissionv1.Create
+}
+
+// isDeletion returns true if the request removes a pod, either by DELETE or by eviction
+func (pv *PodAdmission) isDeletion() bool {
+	if pv.request.Kind.Kind == "Pod" && pv.request.Operation == admissionv1.Delete {
+		return true
+	}
+	return pv.isEviction()
+}
+
+// evictionPolicy returns the policy configured for the user deleting the pod
+func (pv *PodAdmission) evictionPolicy() config.EvictionPolicy {
+	return cfg.EvictionPolicyFor(pv.request.UserInfo.Username, pv.request.UserInfo.Groups)
+}
+
+// isProtected tells whether deleting the pod may break autonomy of its node or pool
+func (pv *PodAdmission) isProtected() bool {
+	if nodeIsCloud(pv.node) {
+		return false
+	}
+	if d, ok := pv.autonomyDuration(); ok {
+		return !autonomyExpired(pv.node.Name, d)
+	}
+	switch pv.pod.Annotations[constant.PodAvailableAnnotation] {
+	case constant.PodAvailableNode, constant.PodAvailablePool:
+		return true
+	}
+	return false
 }
 
 func (pv *PodAdmission) getNode() error {
@@ -75,30 +179,59 @@ func (pv *PodAdmission) getNode() error {
 	return err
 }
 
+// getBoundNode looks up the node of the pod, it returns false if the pod is not bound to a
+// node or its node is gone, as then no autonomy is left to protect
+func (pv *PodAdmission) getBoundNode() (bool, error) {
+	if pv.pod.Spec.NodeName == "" {
+		return false, nil
+	}
+	err := pv.getNode()
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (pv *PodAdmission) validateReview() (*admissionv1.AdmissionReview, error) {
-	if pv.request.Kind.Kind != "Pod" {
-		err := fmt.Errorf("only pods are supported here")
+	if pv.request.Kind.Kind != "Pod" && pv.request.Kind.Kind != "Eviction" {
+		err := fmt.Errorf("only pods and evictions are supported here")
 		return reviewResponse(pv.request.UID, false, http.StatusBadRequest, ""), err
 	}
 
-	if pv.request.Operation != admissionv1.Delete {
+	if !pv.isDeletion() {
 		reason := fmt.Sprintf("Operation %v is accepted always", pv.request.Operation)
 		return reviewResponse(pv.request.UID, true, http.StatusAccepted, reason), nil
 	}
 
-	err := pv.getPod()
-	if err != nil {
-		e := fmt.Sprintf("could not parse pod in admission review request: %v", err)
-		return reviewResponse(pv.request.UID, false, http.StatusBadRequest, e), err
+	if pv.isEviction() {
+		err := pv.getEvictedPod()
+		if err != nil {
+			e := fmt.Sprintf("could not get evicted pod %s/%s: %v", pv.request.Namespace, pv.request.Name, err)
+			return reviewResponse(pv.request.UID, false, http.StatusBadRequest, e), err
+		}
+	} else {
+		err := pv.getPod()
+		if err != nil {
+			e := fmt.Sprintf("could not parse pod in admission review request: %v", err)
+			return reviewResponse(pv.request.UID, false, http.StatusBadRequest, e), err
+		}
 	}
 
-	err = pv.getNode()
+	// the user may delete any pod, whatever its node or pool
+	policy := pv.evictionPolicy()
+	if policy == config.EvictionPolicyAllow {
+		return reviewResponse(pv.request.UID, true, http.StatusAccepted, msgPodDeleteValidated), nil
+	}
+	bound, err := pv.getBoundNode()
 	if err != nil {
 		e := fmt.Sprintf("could not get node object: %s", pv.pod.Spec.NodeName)
 		return reviewResponse(pv.request.UID, false, http.StatusBadRequest, e), err
 	}
+	if !bound {
+		return reviewResponse(pv.request.UID, true, http.StatusAccepted, msgPodNotBound), nil
+	}
 
-	val, err := pv.validateDel()
+	val, err := pv.validateDel(policy)
 
 	if err != nil {
 		e := fmt.Sprintf("could not validate pod: %v", err)
@@ -106,43 +239,129 @@ func (pv *PodAdmission) validateReview() (*admissionv1.AdmissionReview, error) {
 	}
 
 	if !val.Valid {
+		if pv.dryRun() {
+			return pv.dryRunResponse(val), nil
+		}
+		pv.recordDenial(val)
+		if val.RetryAfterSeconds > 0 {
+			out := reviewResponse(pv.request.UID, false, http.StatusTooManyRequests, val.Reason)
+			out.Response.Result.Reason = metav1.StatusReasonTooManyRequests
+			out.Response.Result.Details = &metav1.StatusDetails{RetryAfterSeconds: val.RetryAfterSeconds}
+			return out, nil
+		}
 		return reviewResponse(pv.request.UID, false, http.StatusForbidden, val.Reason), nil
 	}
 
 	return reviewResponse(pv.request.UID, true, http.StatusAccepted, val.Reason), nil
 }
 
-// ValidateDel returns true if a pod is valid to delete/evict
-func (pv *PodAdmission) validateDel() (validation, error) {
-	if pv.request.Operation == admissionv1.Delete {
-		if pv.userIsNodeController() {
-			// node is autonomy annotated
-			if utils.NodeIsInAutonomy(pv.node) {
-				return validation{Valid: false, Reason: msgNodeAutonomy}, nil
-			}
+// pool returns the nodepool of the pod's node, or empty if unknown
+func (pv *PodAdmission) pool() string {
+	if pv.node == nil {
+		return ""
+	}
+	pool, _ := nodePool(pv.node)
+	return pool
+}
 
-			if pv.pod.Annotations != nil {
-				// pod has annotation of node available
-				if pv.pod.Annotations[constant.PodAvailableAnnotation] == "node" {
-					return validation{Valid: false, Reason: msgPodAvailableNode}, nil
-				}
+// recordDenial emits an event on the pod telling why its deletion was denied, unless the
+// request is not persisted
+func (pv *PodAdmission) recordDenial(val validation) {
+	if pv.isDryRunRequest() {
+		return
+	}
+	pool := pv.pool()
+	s := health.Stats(pool)
+	recorder.Eventf(pv.pod, corev1.EventTypeWarning, eventReasonEvictionDenied,
+		"%s, node %s in pool %q with %d nodes, %d alive, %d delegated",
+		val.Reason, pv.pod.Spec.NodeName, pool, s.Nodes, s.Alive, s.Delegated)
+}
+
+// isDryRunRequest returns true if the API server will not persist the request, e.g. for
+// kubectl drain --dry-run=server, so it must have no side effects
+func (pv *PodAdmission) isDryRunRequest() bool {
+	return pv.request.DryRun != nil && *pv.request.DryRun
+}
+
+// dryRun returns true if denials for the pod should only be reported
+func (pv *PodAdmission) dryRun() bool {
+	return cfg.DryRun.IsDryRun(pv.pod.Namespace, pv.pool())
+}
+
+// dryRunResponse allows the request, reporting the denial it would have got
+func (pv *PodAdmission) dryRunResponse(val validation) *admissionv1.AdmissionReview {
+	klog.Infof("dry-run: deletion of pod %s/%s would be denied: %s", pv.pod.Namespace, pv.pod.Name, val.Reason)
+	metrics.DryRunDenials.WithLabelValues(val.Reason).Inc()
 
-				if pv.pod.Annotations[constant.PodAvailableAnnotation] == "pool" {
-					if utils.NodeIsAlive(leaseLister, pv.node.Name) {
-						return validation{Valid: false, Reason: msgPodAvailablePoolAndNodeIsAlive}, nil
-					} else {
-						pool, ok := utils.NodeNodepool(pv.node)
-						if ok {
-							if nodepoolMap.Count(pool) < 3 {
-								return validation{Valid: false, Reason: msgPoolHasTooFewNodes}, nil
-							}
-							if float32(utils.CountAliveNode(leaseLister, nodepoolMap.Nodes(pool)))/float32(nodepoolMap.Count(pool)) < constant.PoolAliveNodeRatio {
-								return validation{Valid: false, Reason: msgPoolHasTooFewReadyNodes}, nil
-							}
-						}
-						return validation{Valid: true, Reason: msgPodAvailablePoolAndNodeIsNotAlive}, nil
+	out := reviewResponse(pv.request.UID, true, http.StatusAccepted, val.Reason)
+	out.Response.Warnings = []string{fmt.Sprintf("pool-coordinator dry-run: deletion would be denied: %s", val.Reason)}
+	return out
+}
+
+// ValidateDel returns true if a pod is valid to delete/evict by a user of policy
+func (pv *PodAdmission) validateDel(policy config.EvictionPolicy) (validation, error) {
+	if !pv.isDeletion() {
+		return validation{Valid: true, Reason: msgPodDeleteValidated}, nil
+	}
+	switch policy {
+	case config.EvictionPolicyDeny:
+		if pv.isProtected() {
+			return validation{Valid: false, Reason: msgIdentityDenied}, nil
+		}
+	case config.EvictionPolicyValidate:
+		val, err := pv.validateProtected()
+		if err != nil || !val.Valid {
+			return val, err
+		}
+		return pv.throttle(val), nil
+	}
+	return validation{Valid: true, Reason: msgPodDeleteValidated}, nil
+}
+
+// validateProtected fully validates the deletion of a pod which may be protected
+func (pv *PodAdmission) validateProtected() (validation, error) {
+	// pods in cloud pools are evicted as usual
+	if nodeIsCloud(pv.node) {
+		return validation{Valid: true, Reason: msgNodeInCloudPool}, nil
+	}
+	// node or pod is autonomy annotated, until autonomy expires
+	if d, ok := pv.autonomyDuration(); ok {
+		if autonomyExpired(pv.node.Name, d) {
+			klog.Infof("autonomy of pod %s/%s on node %s expired after %v", pv.pod.Namespace, pv.pod.Name, pv.node.Name, d)
+			return validation{Valid: true, Reason: msgAutonomyExpired}, nil
+		}
+		return validation{Valid: false, Reason: msgNodeAutonomy}, nil
+	}
+
+	if pv.pod.Annotations != nil {
+		// pod has annotation of node available
+		if pv.pod.Annotations[constant.PodAvailableAnnotation] == "node" {
+			return validation{Valid: false, Reason: msgPodAvailableNode}, nil
+		}
+
+		if pv.pod.Annotations[constant.PodAvailableAnnotation] == "pool" {
+			switch liveness.Status(pv.node.Name) {
+			case utils.LivenessAlive:
+				return validation{Valid: false, Reason: msgPodAvailablePoolAndNodeIsAlive}, nil
+			case utils.LivenessUnknown:
+				return validation{Valid: false, Reason: msgNodeLivenessUnknown}, nil
+			default:
+				if pool, ok := nodePool(pv.node); ok {
+					if reason := checkQuorum(pool); reason != "" {
+						return validation{Valid: false, Reason: reason}, nil
+					}
+					reason, err := pv.checkDisruptionBudgets(pool)
+					if err != nil {
+						return validation{}, err
+					}
+					if reason != "" {
+						return validation{Valid: false, Reason: reason}, nil
+					}
+					if !pv.fitsInPool(pool) {
+						return validation{Valid: false, Reason: msgPoolHasNoCapacity}, nil
 					}
 				}
+				return validation{Valid: true, Reason: msgPodAvailablePoolAndNodeIsNotAlive}, nil
 			}
 		}
 	}
@@ -150,17 +369,10 @@ func (pv *PodAdmission) validateDel() (validation, error) {
 }
 
 func (pv *PodAdmission) mutateAddToleration() ([]byte, error) {
-	toadd := []corev1.Toleration{
-		{Key: "node.kubernetes.io/unreachable",
-			Operator: "Exists",
-			Effect:   "NoExecute"},
-		{Key: "node.kubernetes.io/not-ready",
-			Operator: "Exists",
-			Effect:   "NoExecute"},
-	}
+	toadd := cfg.Tolerations.For(pv.pod.Namespace, pv.pool())
 	tols := pv.pod.Spec.Tolerations
 	merged, changed := utils.MergeTolerations(tols, toadd)
-	if !changed {
+	if !changed || apiequality.Semantic.DeepEqual(merged, tols) {
 		return nil, nil
 	}
 
@@ -181,6 +393,57 @@ func (pv *PodAdmission) mutateAddToleration() ([]byte, error) {
 	return patchb, nil
 }
 
+// candidateNodeNames returns the nodes the pod may run on, as constrained by spec.nodeName,
+// a nodeSelector on the nodepool label, or required node affinity to nodes or nodepools
+func (pv *PodAdmission) candidateNodeNames() []string {
+	if pv.pod.Spec.NodeName != "" {
+		return []string{pv.pod.Spec.NodeName}
+	}
+
+	names := sets.NewString()
+	if pool, ok := pv.pod.Spec.NodeSelector[constant.LabelKeyNodePool]; ok {
+		names.Insert(pools.Nodes(pool)...)
+	}
+
+	affinity := pv.pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil ||
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return names.List()
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == constant.LabelKeyNodePool && expr.Operator == corev1.NodeSelectorOpIn {
+				for _, pool := range expr.Values {
+					names.Insert(pools.Nodes(pool)...)
+				}
+			}
+		}
+		// daemonset pods are bound to their node this way
+		for _, field := range term.MatchFields {
+			if field.Key == metav1.ObjectNameField && field.Operator == corev1.NodeSelectorOpIn {
+				names.Insert(field.Values...)
+			}
+		}
+	}
+
+	return names.List()
+}
+
+// autonomousCandidate returns a candidate node of the pod which is in autonomy, or nil if none
+func (pv *PodAdmission) autonomousCandidate() *corev1.Node {
+	for _, name := range pv.candidateNodeNames() {
+		node, err := nodeLister.Get(name)
+		if err != nil {
+			klog.Warningf("could not get candidate node %s: %v", name, err)
+			continue
+		}
+		if nodeInAutonomy(node) {
+			return node
+		}
+	}
+	return nil
+}
+
 func (pv *PodAdmission) mutateReview() (*admissionv1.AdmissionReview, error) {
 	if pv.request.Kind.Kind != "Pod" {
 		err := fmt.Errorf("only pods are supported here")
@@ -192,24 +455,25 @@ func (pv *PodAdmission) mutateReview() (*admissionv1.AdmissionReview, error) {
 		return reviewResponse(pv.request.UID, true, http.StatusAccepted, reason), nil
 	}
 
-	err := pv.getPod()
+	err := pv.getNewPod()
 	if err != nil {
 		e := fmt.Sprintf("could not parse pod in admission review request: %v", err)
 		return reviewResponse(pv.request.UID, false, http.StatusBadRequest, e), err
 	}
 
-	if !utils.NodeIsInAutonomy(pv.node) &&
+	pv.node = pv.autonomousCandidate()
+	if pv.node == nil &&
 		(pv.pod.Annotations == nil || pv.pod.Annotations[constant.PodAvailableAnnotation] != constant.PodAvailableNode) {
-		return reviewResponse(pv.request.UID, true, http.StatusAccepted, "no need of mutation"), nil
+		return reviewResponse(pv.request.UID, true, http.StatusAccepted, msgNoNeedOfMutation), nil
 	}
 
 	// add tolerations if not yet
 	val, err := pv.mutateAddToleration()
 	if err != nil {
-		return reviewResponse(pv.request.UID, true, http.StatusAccepted, "could not merge tolerations"), err
+		return reviewResponse(pv.request.UID, true, http.StatusAccepted, msgCouldNotMergeTolerations), err
 	}
 	if val == nil {
-		return reviewResponse(pv.request.UID, true, http.StatusAccepted, "tolerations already existed"), nil
+		return reviewResponse(pv.request.UID, true, http.StatusAccepted, msgTolerationsAlreadyExisted), nil
 	}
 
 	return patchReviewResponse(pv.request.UID, val)
@@ -250,12 +514,51 @@ func patchReviewResponse(uid types.UID, patch []byte) (*admissionv1.AdmissionRev
 	}, nil
 }
 
+// recordDecision exports the outcome and latency of an admission response
+func recordDecision(webhook string, out *admissionv1.AdmissionReview, start time.Time) {
+	metrics.AdmissionDuration.WithLabelValues(webhook).Observe(time.Since(start).Seconds())
+	if out == nil || out.Response == nil {
+		return
+	}
+
+	outcome, reason := "allowed", msgTolerationsAdded
+	if !out.Response.Allowed {
+		outcome = "denied"
+	}
+	if out.Response.Result != nil {
+		reason = out.Response.Result.Message
+		// error messages carry object names, keep label cardinality bounded
+		if out.Response.Result.Code == http.StatusBadRequest {
+			outcome, reason = "error", msgBadRequest
+		}
+	}
+	metrics.AdmissionDecisions.WithLabelValues(webhook, outcome, reason).Inc()
+}
+
 // ServeHealth returns 200 when things are good
 func serveHealth(w http.ResponseWriter, r *http.Request) {
 	klog.Info("uri", r.RequestURI)
 	fmt.Fprint(w, "OK")
 }
 
+// Validate answers admission review in as the validating webhook, on the state given to Setup
+func Validate(in *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, error) {
+	pv := &PodAdmission{
+		request: in.Request,
+		pod:     &corev1.Pod{},
+	}
+	return pv.validateReview()
+}
+
+// Mutate answers admission review in as the mutating webhook, on the state given to Setup
+func Mutate(in *admissionv1.AdmissionReview) (*admissionv1.AdmissionReview, error) {
+	pv := &PodAdmission{
+		request: in.Request,
+		pod:     &corev1.Pod{},
+	}
+	return pv.mutateReview()
+}
+
 // ServeValidatePods validates an admission request and then writes an admission
 func serveValidatePods(w http.ResponseWriter, r *http.Request) {
 	klog.Info("uri", r.RequestURI)
@@ -268,15 +571,12 @@ func serveValidatePods(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pv := &PodAdmission{
-		request: in.Request,
-		pod:     &corev1.Pod{},
-	}
-
 	klog.Infof("name: %s, namespace: %s, operation: %s, from: %v",
 		in.Request.Name, in.Request.Namespace, in.Request.Operation, &in.Request.UserInfo)
 
-	out, err := pv.validateReview()
+	start := time.Now()
+	out, err := Validate(in)
+	recordDecision("validate", out, start)
 
 	if err != nil {
 		e := fmt.Sprintf("could not generate admission response: %v", err)
@@ -311,15 +611,12 @@ func serveMutatePods(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pv := &PodAdmission{
-		request: in.Request,
-		pod:     &corev1.Pod{},
-	}
-
 	klog.Infof("name: %s, namespace: %s, operation: %s, from: %v",
 		in.Request.Name, in.Request.Namespace, in.Request.Operation, &in.Request.UserInfo)
 
-	out, err := pv.mutateReview()
+	start := time.Now()
+	out, err := Mutate(in)
+	recordDecision("mutate", out, start)
 
 	if err != nil {
 		e := fmt.Sprintf("could not generate admission response: %v", err)
@@ -374,14 +671,77 @@ const (
 	CertDir string = "/tmp/k8s-webhook-server/serving-certs"
 )
 
-func Run(nLister listerv1.NodeLister, lLister leaselisterv1.LeaseNamespaceLister, npm *utils.NodepoolMap) {
+// Setup gives the webhook the configuration and cluster state it decides on
+func Setup(c *config.Config, nLister listerv1.NodeLister, pLister listerv1.PodLister, pIndexer cache.Indexer, pdbl policylisterv1.PodDisruptionBudgetLister, lt *utils.LivenessTracker, p *utils.Pools, h *utils.PoolHealth, r record.EventRecorder) {
+	cfg = c
 	nodeLister = nLister
-	leaseLister = lLister
+	podLister = pLister
+	podIndexer = pIndexer
+	pdbLister = pdbl
+	liveness = lt
+	pools = p
+	health = h
+	recorder = r
+}
+
+func Run(c *config.Config, nLister listerv1.NodeLister, pLister listerv1.PodLister, pIndexer cache.Indexer, pdbl policylisterv1.PodDisruptionBudgetLister, lt *utils.LivenessTracker, p *utils.Pools, h *utils.PoolHealth, r record.EventRecorder) {
+	Setup(c, nLister, pLister, pIndexer, pdbl, lt, p, h, r)
 
 	http.HandleFunc(ValidatePath, serveValidatePods)
 	http.HandleFunc(MutatePath, serveMutatePods)
 	http.HandleFunc(HealthPath, serveHealth)
+	http.Handle(MetricsPath, promhttp.Handler())
+
+	client := client.GetClientFromCluster()
+	stopper := make(chan (struct{}))
+
+	var getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	var caBundle []byte
+	if cfg.Certificates.SelfManaged {
+		mgr := certs.NewManager(client, cfg)
+		for {
+			err := mgr.Ensure()
+			if err == nil {
+				klog.Info("self-managed tls key and cert ok.")
+				break
+			}
+			klog.Errorf("could not ensure webhook certificates: %v", err)
+			time.Sleep(time.Second)
+		}
+		go mgr.Run(stopper)
+		getCertificate = mgr.GetCertificate
+		caBundle = mgr.CABundle()
+	} else {
+		watcher := waitForCertFiles()
+		go watcher.Run(stopper)
+		getCertificate = watcher.GetCertificate
+	}
 
+	// listen before registering, so the API server never calls a webhook not yet served
+	ln, err := net.Listen("tcp", ":443")
+	if err != nil {
+		klog.Fatal(err)
+	}
+	if cfg.Webhook.Register {
+		for {
+			err := registerWebhooks(client, cfg.Webhook, caBundle)
+			if err == nil {
+				break
+			}
+			klog.Errorf("could not register webhook configurations: %v", err)
+			time.Sleep(time.Second)
+		}
+	}
+
+	server := &http.Server{
+		TLSConfig: &tls.Config{GetCertificate: getCertificate},
+	}
+	klog.Info("Listening on port 443...")
+	klog.Fatal(server.ServeTLS(ln, "", ""))
+}
+
+// waitForCertFiles blocks until a valid key pair shows up in CertDir
+func waitForCertFiles() *certs.Watcher {
 	err := utils.EnsureDir(CertDir)
 	if err != nil {
 		klog.Error(err)
@@ -391,20 +751,15 @@ func Run(nLister listerv1.NodeLister, lLister leaselisterv1.LeaseNamespaceLister
 
 	for {
 		if utils.FileExists(cert) && utils.FileExists(key) {
-			klog.Info("tls key and cert ok.")
-			break
+			watcher, err := certs.NewWatcher(cert, key)
+			if err == nil {
+				klog.Info("tls key and cert ok.")
+				return watcher
+			}
+			klog.Errorf("could not load tls key and cert: %v", err)
 		} else {
 			klog.Info("Wating for tls key and cert...")
-			time.Sleep(time.Second)
 		}
+		time.Sleep(time.Second)
 	}
-
-	client := client.GetClientFromCluster()
-	stopper := make(chan (struct{}))
-	nodeLister = lister.CreateNodeLister(client, stopper, nil, nil, nil)
-
-	nodepoolMap = npm
-
-	klog.Info("Listening on port 443...")
-	klog.Fatal(http.ListenAndServeTLS(":443", cert, key, nil))
 }
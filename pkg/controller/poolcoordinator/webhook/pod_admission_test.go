@@ -0,0 +1,579 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func setupListers(t *testing.T, nodes []*corev1.Node, pods []*corev1.Pod) cache.Indexer {
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodeIndexers())
+	for _, n := range nodes {
+		if err := nodeIndexer.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	podStore := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.PodIndexers())
+	for _, p := range pods {
+		if err := podStore.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodeLister = listerv1.NewNodeLister(nodeIndexer)
+	podLister = listerv1.NewPodLister(podStore)
+	pools = utils.NewPools(nodeIndexer, nil)
+	health = utils.NewPoolHealth()
+	liveness = utils.NewLivenessTracker(1)
+	pdbLister = nil
+	podIndexer = nil
+	recorder = record.NewFakeRecorder(100)
+	resetThrottles()
+	return nodeIndexer
+}
+
+// syncHealth records pool health of every node, as the controller does
+func syncHealth(t *testing.T) {
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		if pool, ok := pools.Pool(n); ok {
+			health.Set(n.Name, pool, liveness.Status(n.Name) == utils.LivenessAlive, false)
+		}
+	}
+}
+
+// observeLease records a renewal of the lease of node name at renewed
+func observeLease(name string, renewed time.Time) {
+	liveness.ObserveRenewal(nodeLease(name, renewed), renewed)
+}
+
+func nodeLease(name string, renewed time.Time) *coordv1.Lease {
+	duration := int32(40)
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: corev1.NamespaceNodeLease},
+		Spec: coordv1.LeaseSpec{
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &metav1.MicroTime{Time: renewed},
+		},
+	}
+}
+
+func evictionRequest(t *testing.T, namespace, name string) *admissionv1.AdmissionRequest {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+	raw, err := json.Marshal(eviction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &admissionv1.AdmissionRequest{
+		Kind:        metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+		Resource:    metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+		SubResource: "eviction",
+		Name:        name,
+		Namespace:   namespace,
+		Operation:   admissionv1.Create,
+		UserInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"},
+		Object:      runtime.RawExtension{Raw: raw},
+	}
+}
+
+func TestValidateEviction(t *testing.T) {
+	cfg = config.Default()
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	setupListers(t, []*corev1.Node{node}, []*corev1.Pod{pod})
+
+	pv := &PodAdmission{
+		request: evictionRequest(t, "default", "pod1"),
+		pod:     &corev1.Pod{},
+	}
+
+	out, err := pv.validateReview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Response.Allowed {
+		t.Errorf("expect %v, but %v returned", false, out.Response.Allowed)
+	}
+	if out.Response.Result.Message != msgNodeAutonomy {
+		t.Errorf("expect %v, but %v returned", msgNodeAutonomy, out.Response.Result.Message)
+	}
+	select {
+	case e := <-recorder.(*record.FakeRecorder).Events:
+		if !strings.Contains(e, eventReasonEvictionDenied) || !strings.Contains(e, msgNodeAutonomy) {
+			t.Errorf("expect event %s citing %q, but %q returned", eventReasonEvictionDenied, msgNodeAutonomy, e)
+		}
+	default:
+		t.Errorf("expect event %s, but none recorded", eventReasonEvictionDenied)
+	}
+
+	// requests not persisted are denied without an event
+	dryRun := true
+	pv = &PodAdmission{
+		request: evictionRequest(t, "default", "pod1"),
+		pod:     &corev1.Pod{},
+	}
+	pv.request.DryRun = &dryRun
+	out, err = pv.validateReview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Response.Allowed {
+		t.Errorf("expect %v, but %v returned", false, out.Response.Allowed)
+	}
+	if n := len(recorder.(*record.FakeRecorder).Events); n != 0 {
+		t.Errorf("expect %v, but %v returned", 0, n)
+	}
+}
+
+func TestValidateAutonomyDuration(t *testing.T) {
+	cfg = config.Default()
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomyDuration: "10m"},
+		},
+	}
+	tests := []struct {
+		name        string
+		renewed     time.Time
+		annotations map[string]string
+		allowed     bool
+		reason      string
+	}{
+		{"alive", time.Now(), nil, false, msgNodeAutonomy},
+		{"lost within duration", time.Now().Add(-5 * time.Minute), nil, false, msgNodeAutonomy},
+		{"lost beyond duration", time.Now().Add(-20 * time.Minute), nil, true, msgAutonomyExpired},
+		{"pod takes precedence", time.Now().Add(-20 * time.Minute),
+			map[string]string{constant.PodAutonomyDurationAnnotation: "1h"}, false, msgNodeAutonomy},
+		{"pod without limit", time.Now().Add(-20 * time.Minute),
+			map[string]string{constant.PodAutonomyDurationAnnotation: "0"}, false, msgNodeAutonomy},
+	}
+	for _, tt := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default", Annotations: tt.annotations},
+			Spec:       corev1.PodSpec{NodeName: "node1"},
+		}
+		setupListers(t, []*corev1.Node{node}, []*corev1.Pod{pod})
+		observeLease("node1", tt.renewed)
+
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", "pod1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+		if out.Response.Result.Message != tt.reason {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.reason, out.Response.Result.Message)
+		}
+	}
+}
+
+func TestValidateEvictionPodNotFound(t *testing.T) {
+	setupListers(t, nil, nil)
+
+	pv := &PodAdmission{
+		request: &admissionv1.AdmissionRequest{
+			Kind:        metav1.GroupVersionKind{Group: "policy", Version: "v1", Kind: "Eviction"},
+			SubResource: "eviction",
+			Name:        "missing",
+			Namespace:   "default",
+			Operation:   admissionv1.Create,
+			Object:      runtime.RawExtension{Raw: []byte(`{}`)},
+		},
+		pod: &corev1.Pod{},
+	}
+
+	out, err := pv.validateReview()
+	if err == nil {
+		t.Errorf("expect error, but nil returned")
+	}
+	if out.Response.Allowed {
+		t.Errorf("expect %v, but %v returned", false, out.Response.Allowed)
+	}
+}
+
+func TestValidateUnboundPod(t *testing.T) {
+	cfg = config.Default()
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "orphaned", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "gone"},
+		},
+	}
+	setupListers(t, nil, pods)
+
+	for _, pod := range pods {
+		raw, err := json.Marshal(pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deletion := &admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "pods"},
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Operation: admissionv1.Delete,
+			UserInfo:  authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"},
+			OldObject: runtime.RawExtension{Raw: raw},
+		}
+		for _, req := range []*admissionv1.AdmissionRequest{deletion, evictionRequest(t, pod.Namespace, pod.Name)} {
+			pv := &PodAdmission{request: req, pod: &corev1.Pod{}}
+			out, err := pv.validateReview()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !out.Response.Allowed {
+				t.Errorf("%s %s: expect %v, but %v returned", req.Operation, pod.Name, true, out.Response.Allowed)
+			}
+			if out.Response.Result.Message != msgPodNotBound {
+				t.Errorf("%s %s: expect %v, but %v returned", req.Operation, pod.Name, msgPodNotBound, out.Response.Result.Message)
+			}
+		}
+	}
+}
+
+func TestValidatePoolPodLiveness(t *testing.T) {
+	cfg = config.Default()
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2", "node3"} {
+		nodes = append(nodes, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{constant.LabelKeyNodePool: "pool1"},
+			},
+		})
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod1",
+			Namespace:   "default",
+			Annotations: map[string]string{constant.PodAvailableAnnotation: constant.PodAvailablePool},
+		},
+		Spec: corev1.PodSpec{NodeName: "node1"},
+	}
+
+	tests := []struct {
+		name    string
+		leases  []*coordv1.Lease
+		allowed bool
+		reason  string
+	}{
+		{"no lease", nil, false, msgNodeLivenessUnknown},
+		{"alive", []*coordv1.Lease{nodeLease("node1", time.Now())}, false, msgPodAvailablePoolAndNodeIsAlive},
+		{"dead", []*coordv1.Lease{
+			nodeLease("node1", time.Now().Add(-time.Minute)),
+			nodeLease("node2", time.Now()),
+		}, true, msgPodAvailablePoolAndNodeIsNotAlive},
+		{"dead in a dead pool", []*coordv1.Lease{
+			nodeLease("node1", time.Now().Add(-time.Minute)),
+		}, false, msgPoolAliveRatioTooLow},
+	}
+	for _, tt := range tests {
+		setupListers(t, nodes, []*corev1.Pod{pod})
+		for _, l := range tt.leases {
+			liveness.ObserveRenewal(l, l.Spec.RenewTime.Time)
+		}
+		syncHealth(t)
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", "pod1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+		if out.Response.Result.Message != tt.reason {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.reason, out.Response.Result.Message)
+		}
+	}
+}
+
+func TestValidateIdentities(t *testing.T) {
+	cfg = config.Default()
+	cfg.Identities = []config.IdentityConfig{
+		{Users: []string{"descheduler"}, Policy: config.EvictionPolicyDeny},
+		{Groups: []string{"system:masters"}, Policy: config.EvictionPolicyAllow},
+		{ServiceAccounts: []string{"kube-system/node-controller"}, Policy: config.EvictionPolicyValidate},
+	}
+	defer func() { cfg = config.Default() }()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	setupListers(t, []*corev1.Node{node}, []*corev1.Pod{pod})
+
+	tests := []struct {
+		user    authenticationv1.UserInfo
+		allowed bool
+		reason  string
+	}{
+		{authenticationv1.UserInfo{Username: "descheduler"}, false, msgIdentityDenied},
+		{authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}}, true, msgPodDeleteValidated},
+		{authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller"}, false, msgNodeAutonomy},
+		// names are matched exactly
+		{authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:node-controller-fake"}, true, msgPodDeleteValidated},
+	}
+	for _, tt := range tests {
+		req := evictionRequest(t, "default", "pod1")
+		req.UserInfo = tt.user
+		pv := &PodAdmission{request: req, pod: &corev1.Pod{}}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.user.Username, tt.allowed, out.Response.Allowed)
+		}
+		if out.Response.Result.Message != tt.reason {
+			t.Errorf("%s: expect %v, but %v returned", tt.user.Username, tt.reason, out.Response.Result.Message)
+		}
+		// allowed users are approved without looking up the node
+		if policy := cfg.EvictionPolicyFor(tt.user.Username, tt.user.Groups); policy == config.EvictionPolicyAllow && pv.node != nil {
+			t.Errorf("%s: expect node not looked up, but %s returned", tt.user.Username, pv.node.Name)
+		}
+	}
+}
+
+func TestValidateDryRun(t *testing.T) {
+	cfg = &config.Config{
+		DryRun: config.DryRunConfig{
+			Enabled:    true,
+			Namespaces: map[string]bool{"enforced": false},
+		},
+		Identities: config.Default().Identities,
+	}
+	defer func() { cfg = config.Default() }()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"},
+		},
+	}
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node1"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "enforced"},
+			Spec:       corev1.PodSpec{NodeName: "node1"},
+		},
+	}
+	setupListers(t, []*corev1.Node{node}, pods)
+
+	tests := []struct {
+		namespace string
+		allowed   bool
+		warnings  int
+	}{
+		{"default", true, 1},
+		{"enforced", false, 0},
+	}
+	for _, tt := range tests {
+		pv := &PodAdmission{
+			request: evictionRequest(t, tt.namespace, "pod1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.namespace, tt.allowed, out.Response.Allowed)
+		}
+		if len(out.Response.Warnings) != tt.warnings {
+			t.Errorf("%s: expect %v, but %v returned", tt.namespace, tt.warnings, len(out.Response.Warnings))
+		}
+	}
+}
+
+func TestMutateCandidateNodes(t *testing.T) {
+	cfg = config.Default()
+	nodes := []*corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "node1",
+				Labels:      map[string]string{constant.LabelKeyNodePool: "pool1"},
+				Annotations: map[string]string{constant.AnnotationKeyNodeAutonomy: "true"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node2",
+				Labels: map[string]string{constant.LabelKeyNodePool: "pool2"},
+			},
+		},
+	}
+	setupListers(t, nodes, nil)
+
+	poolAffinity := func(pool string) *corev1.Affinity {
+		return &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{Key: constant.LabelKeyNodePool, Operator: corev1.NodeSelectorOpIn, Values: []string{pool}},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		spec    corev1.PodSpec
+		patched bool
+	}{
+		{"no constraint", corev1.PodSpec{}, false},
+		{"node name", corev1.PodSpec{NodeName: "node1"}, true},
+		{"node name not autonomous", corev1.PodSpec{NodeName: "node2"}, false},
+		{"unknown node name", corev1.PodSpec{NodeName: "node3"}, false},
+		{"pool selector", corev1.PodSpec{NodeSelector: map[string]string{constant.LabelKeyNodePool: "pool1"}}, true},
+		{"pool selector not autonomous", corev1.PodSpec{NodeSelector: map[string]string{constant.LabelKeyNodePool: "pool2"}}, false},
+		{"pool affinity", corev1.PodSpec{Affinity: poolAffinity("pool1")}, true},
+		{"pool affinity not autonomous", corev1.PodSpec{Affinity: poolAffinity("pool2")}, false},
+	}
+	for _, tt := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+			Spec:       tt.spec,
+		}
+		raw, err := json.Marshal(pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pv := &PodAdmission{
+			request: &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+			pod: &corev1.Pod{},
+		}
+
+		out, err := pv.mutateReview()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		patched := out.Response.Patch != nil
+		if patched != tt.patched {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.patched, patched)
+		}
+	}
+}
+
+func TestMutateTolerations(t *testing.T) {
+	cfg = config.Default()
+	seconds := func(s int64) *int64 { return &s }
+	video := []corev1.Toleration{{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists,
+		Effect: corev1.TaintEffectNoExecute, TolerationSeconds: seconds(7200)}}
+	pool1 := []corev1.Toleration{{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists,
+		Effect: corev1.TaintEffectNoExecute, TolerationSeconds: seconds(300)}}
+	cfg.Tolerations.Namespaces = map[string][]corev1.Toleration{"video": video}
+	cfg.Tolerations.Pools = map[string][]corev1.Toleration{"pool1": pool1}
+
+	autonomy := map[string]string{constant.AnnotationKeyNodeAutonomy: "true"}
+	setupListers(t, []*corev1.Node{poolNode("node1", "pool1", autonomy), poolNode("node2", "pool2", autonomy)}, nil)
+
+	tests := []struct {
+		name      string
+		namespace string
+		node      string
+		expect    []corev1.Toleration
+	}{
+		{"namespace override", "video", "node1", video},
+		{"pool override", "default", "node1", pool1},
+		{"default set", "default", "node2", cfg.Tolerations.Default},
+	}
+	for _, tt := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: tt.namespace},
+			Spec:       corev1.PodSpec{NodeName: tt.node},
+		}
+		raw, err := json.Marshal(pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pv := &PodAdmission{
+			request: &admissionv1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Namespace: tt.namespace,
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+			pod: &corev1.Pod{},
+		}
+
+		out, err := pv.mutateReview()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		patch := []struct {
+			Op    string              `json:"op"`
+			Path  string              `json:"path"`
+			Value []corev1.Toleration `json:"value"`
+		}{}
+		if err := json.Unmarshal(out.Response.Patch, &patch); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(patch) != 1 || patch[0].Op != "add" || patch[0].Path != "/spec/tolerations" {
+			t.Errorf("%s: expect add of /spec/tolerations, but %s returned", tt.name, out.Response.Patch)
+			continue
+		}
+		if !apiequality.Semantic.DeepEqual(patch[0].Value, tt.expect) {
+			t.Errorf("%s: expect %+v, but %+v returned", tt.name, tt.expect, patch[0].Value)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"strconv"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// poolQuorum returns the quorum of pool. An annotation on the NodePool takes precedence over
+// one on its nodes; when nodes disagree, the strictest value wins.
+func poolQuorum(pool string) config.QuorumConfig {
+	q := cfg.Quorum.Override(nodeQuorumAnnotations(pools.QuorumNodes(pool)))
+	if np := pools.NodePool(pool); np != nil {
+		return q.Override(np.Annotations)
+	}
+	return q
+}
+
+// nodeQuorumAnnotations collects the strictest value of each quorum annotation on nodes
+func nodeQuorumAnnotations(nodes []*corev1.Node) map[string]string {
+	strictest := map[string]float64{}
+	annotations := map[string]string{}
+	for _, node := range nodes {
+		for _, key := range utils.QuorumAnnotations {
+			v, ok := node.Annotations[key]
+			if !ok {
+				continue
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				klog.Warningf("ignoring annotation %s=%q of node %s: %v", key, v, node.Name, err)
+				continue
+			}
+			if old, ok := strictest[key]; !ok || f > old {
+				strictest[key] = f
+				annotations[key] = v
+			}
+		}
+	}
+	return annotations
+}
+
+// checkQuorum returns the denial reason naming the threshold pool breaks, or empty if none
+func checkQuorum(pool string) string {
+	q := poolQuorum(pool)
+	// nodes of unknown liveness are not counted as alive
+	s := health.Stats(pool)
+
+	reason := ""
+	switch {
+	case s.Nodes < q.MinNodes:
+		reason = msgPoolHasTooFewNodes
+	case s.Alive < q.MinAliveNodes:
+		reason = msgPoolHasTooFewAliveNodes
+	case s.Nodes > 0 && float64(s.Alive)/float64(s.Nodes) < q.MinAliveRatio:
+		reason = msgPoolAliveRatioTooLow
+	}
+	if reason != "" {
+		klog.Infof("nodepool %s below quorum, %d nodes, %d alive, want minNodes %d, minAliveNodes %d, minAliveRatio %v",
+			pool, s.Nodes, s.Alive, q.MinNodes, q.MinAliveNodes, q.MinAliveRatio)
+	}
+	return reason
+}
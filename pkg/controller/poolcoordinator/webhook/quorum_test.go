@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/constant"
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func poolNode(name, pool string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{constant.LabelKeyNodePool: pool},
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestCheckQuorum(t *testing.T) {
+	cfg = config.Default()
+	nodes := []*corev1.Node{
+		// a two node kiosk lowers minNodes on one of its nodes, the strictest value wins
+		poolNode("kiosk1", "kiosk", map[string]string{constant.AnnotationKeyMinNodes: "1"}),
+		poolNode("kiosk2", "kiosk", map[string]string{constant.AnnotationKeyMinNodes: "2"}),
+		poolNode("factory1", "factory", nil),
+		poolNode("factory2", "factory", nil),
+		poolNode("factory3", "factory", nil),
+		poolNode("factory4", "factory", nil),
+	}
+	nodeIndexer := setupListers(t, nodes, nil)
+	for _, n := range []string{"kiosk2", "factory2", "factory3"} {
+		observeLease(n, time.Now())
+	}
+	syncHealth(t)
+
+	if reason := checkQuorum("kiosk"); reason != "" {
+		t.Errorf("expect no denial, but %v returned", reason)
+	}
+	if reason := checkQuorum("factory"); reason != "" {
+		t.Errorf("expect no denial, but %v returned", reason)
+	}
+
+	// the NodePool overrides its nodes
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, utils.NodePoolIndexers())
+	np := &unstructured.Unstructured{}
+	np.SetAPIVersion("apps.openyurt.io/v1beta1")
+	np.SetKind("NodePool")
+	np.SetName("factory")
+	np.SetAnnotations(map[string]string{constant.AnnotationKeyMinAliveNodes: "3"})
+	if err := indexer.Add(np); err != nil {
+		t.Fatal(err)
+	}
+	pools = utils.NewPools(nodeIndexer, indexer)
+	if reason := checkQuorum("factory"); reason != msgPoolHasTooFewAliveNodes {
+		t.Errorf("expect %v, but %v returned", msgPoolHasTooFewAliveNodes, reason)
+	}
+
+	cfg.Quorum.MinAliveRatio = 0.5
+	if reason := checkQuorum("kiosk"); reason != "" {
+		t.Errorf("expect no denial, but %v returned", reason)
+	}
+	cfg.Quorum.MinAliveRatio = 0.6
+	if reason := checkQuorum("kiosk"); reason != msgPoolAliveRatioTooLow {
+		t.Errorf("expect %v, but %v returned", msgPoolAliveRatioTooLow, reason)
+	}
+	if reason := checkQuorum("nowhere"); reason != msgPoolHasTooFewNodes {
+		t.Errorf("expect %v, but %v returned", msgPoolHasTooFewNodes, reason)
+	}
+}
@@ -0,0 +1,275 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	MutatingWebhookName   string = "mutate.pool-coordinator.openyurt.io"
+	ValidatingWebhookName string = "validate.pool-coordinator.openyurt.io"
+
+	// label of the controller's own pods, see config.WebhookConfig.SelfName
+	labelKeyName = "app.kubernetes.io/name"
+)
+
+func clientConfig(wc config.WebhookConfig, path string, caBundle []byte) admissionregistrationv1.WebhookClientConfig {
+	p := path
+	port := wc.ServicePort
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: wc.Namespace,
+			Name:      wc.ServiceName,
+			Path:      &p,
+			Port:      &port,
+		},
+		CABundle: caBundle,
+	}
+}
+
+func podRule(operations []admissionregistrationv1.OperationType, resource string) admissionregistrationv1.RuleWithOperations {
+	scope := admissionregistrationv1.NamespacedScope
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: operations,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{""},
+			APIVersions: []string{"v1"},
+			Resources:   []string{resource},
+			Scope:       &scope,
+		},
+	}
+}
+
+// objectSelector returns the configured object selector, never selecting the controller's
+// own pods
+func objectSelector(wc config.WebhookConfig) *metav1.LabelSelector {
+	if wc.SelfName == "" {
+		return wc.ObjectSelector
+	}
+	selector := &metav1.LabelSelector{}
+	if wc.ObjectSelector != nil {
+		selector = wc.ObjectSelector.DeepCopy()
+	}
+	selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+		Key:      labelKeyName,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   []string{wc.SelfName},
+	})
+	return selector
+}
+
+// mutatingWebhook builds the mutating webhook served at MutatePath
+func mutatingWebhook(wc config.WebhookConfig, caBundle []byte) admissionregistrationv1.MutatingWebhook {
+	failurePolicy := wc.FailurePolicy
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeout := wc.TimeoutSeconds
+	return admissionregistrationv1.MutatingWebhook{
+		Name:         MutatingWebhookName,
+		ClientConfig: clientConfig(wc, MutatePath, caBundle),
+		Rules: []admissionregistrationv1.RuleWithOperations{
+			podRule([]admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, "pods"),
+		},
+		FailurePolicy:           &failurePolicy,
+		SideEffects:             &sideEffects,
+		TimeoutSeconds:          &timeout,
+		NamespaceSelector:       wc.NamespaceSelector,
+		ObjectSelector:          objectSelector(wc),
+		AdmissionReviewVersions: []string{"v1"},
+	}
+}
+
+// validatingWebhook builds the validating webhook served at ValidatePath
+func validatingWebhook(wc config.WebhookConfig, caBundle []byte) admissionregistrationv1.ValidatingWebhook {
+	failurePolicy := wc.FailurePolicy
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeout := wc.TimeoutSeconds
+	return admissionregistrationv1.ValidatingWebhook{
+		Name:         ValidatingWebhookName,
+		ClientConfig: clientConfig(wc, ValidatePath, caBundle),
+		Rules: []admissionregistrationv1.RuleWithOperations{
+			podRule([]admissionregistrationv1.OperationType{admissionregistrationv1.Delete}, "pods"),
+			podRule([]admissionregistrationv1.OperationType{admissionregistrationv1.Create}, "pods/eviction"),
+		},
+		FailurePolicy:           &failurePolicy,
+		SideEffects:             &sideEffects,
+		TimeoutSeconds:          &timeout,
+		NamespaceSelector:       wc.NamespaceSelector,
+		ObjectSelector:          objectSelector(wc),
+		AdmissionReviewVersions: []string{"v1"},
+	}
+}
+
+// setDefaultRules defaults rules as the API server does
+func setDefaultRules(rules []admissionregistrationv1.RuleWithOperations) {
+	for i := range rules {
+		if rules[i].Scope == nil {
+			scope := admissionregistrationv1.AllScopes
+			rules[i].Scope = &scope
+		}
+	}
+}
+
+// setDefaultClientConfig defaults cc as the API server does
+func setDefaultClientConfig(cc *admissionregistrationv1.WebhookClientConfig) {
+	if cc.Service != nil && cc.Service.Port == nil {
+		port := int32(443)
+		cc.Service.Port = &port
+	}
+}
+
+// setDefaultSelectors defaults the selectors of a webhook as the API server does
+func setDefaultSelectors(namespaceSelector, objectSelector **metav1.LabelSelector) {
+	if *namespaceSelector == nil {
+		*namespaceSelector = &metav1.LabelSelector{}
+	}
+	if *objectSelector == nil {
+		*objectSelector = &metav1.LabelSelector{}
+	}
+}
+
+// setDefaultMutatingWebhook defaults w as the API server does, so that the webhook we want
+// compares equal to the one registered
+func setDefaultMutatingWebhook(w *admissionregistrationv1.MutatingWebhook) {
+	if w.FailurePolicy == nil {
+		policy := admissionregistrationv1.Fail
+		w.FailurePolicy = &policy
+	}
+	if w.MatchPolicy == nil {
+		policy := admissionregistrationv1.Equivalent
+		w.MatchPolicy = &policy
+	}
+	if w.ReinvocationPolicy == nil {
+		policy := admissionregistrationv1.NeverReinvocationPolicy
+		w.ReinvocationPolicy = &policy
+	}
+	if w.TimeoutSeconds == nil {
+		timeout := int32(10)
+		w.TimeoutSeconds = &timeout
+	}
+	setDefaultSelectors(&w.NamespaceSelector, &w.ObjectSelector)
+	setDefaultRules(w.Rules)
+	setDefaultClientConfig(&w.ClientConfig)
+}
+
+// setDefaultValidatingWebhook defaults w as the API server does, so that the webhook we
+// want compares equal to the one registered
+func setDefaultValidatingWebhook(w *admissionregistrationv1.ValidatingWebhook) {
+	if w.FailurePolicy == nil {
+		policy := admissionregistrationv1.Fail
+		w.FailurePolicy = &policy
+	}
+	if w.MatchPolicy == nil {
+		policy := admissionregistrationv1.Equivalent
+		w.MatchPolicy = &policy
+	}
+	if w.TimeoutSeconds == nil {
+		timeout := int32(10)
+		w.TimeoutSeconds = &timeout
+	}
+	setDefaultSelectors(&w.NamespaceSelector, &w.ObjectSelector)
+	setDefaultRules(w.Rules)
+	setDefaultClientConfig(&w.ClientConfig)
+}
+
+// registerWebhooks creates or updates both webhook configurations. A nil caBundle keeps
+// the one already registered, e.g. when it is injected by cert-manager.
+func registerWebhooks(client kubernetes.Interface, wc config.WebhookConfig, caBundle []byte) error {
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return registerMutatingWebhook(client, wc, caBundle)
+	}); err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return registerValidatingWebhook(client, wc, caBundle)
+	})
+}
+
+func registerMutatingWebhook(client kubernetes.Interface, wc config.WebhookConfig, caBundle []byte) error {
+	mwcClient := client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	mwc, err := mwcClient.Get(context.TODO(), wc.MutatingWebhookConfiguration, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		mwc = &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: wc.MutatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{mutatingWebhook(wc, caBundle)},
+		}
+		klog.Infof("creating mutating webhook configuration %s", mwc.Name)
+		_, err = mwcClient.Create(context.TODO(), mwc, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return apierrors.NewConflict(admissionregistrationv1.Resource("mutatingwebhookconfigurations"), mwc.Name, err)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if caBundle == nil {
+		for _, w := range mwc.Webhooks {
+			if w.Name == MutatingWebhookName {
+				caBundle = w.ClientConfig.CABundle
+			}
+		}
+	}
+	webhooks := []admissionregistrationv1.MutatingWebhook{mutatingWebhook(wc, caBundle)}
+	mwc = mwc.DeepCopy()
+	for i := range mwc.Webhooks {
+		setDefaultMutatingWebhook(&mwc.Webhooks[i])
+	}
+	setDefaultMutatingWebhook(&webhooks[0])
+	if apiequality.Semantic.DeepEqual(mwc.Webhooks, webhooks) {
+		return nil
+	}
+	mwc.Webhooks = webhooks
+	klog.Infof("updating mutating webhook configuration %s", mwc.Name)
+	_, err = mwcClient.Update(context.TODO(), mwc, metav1.UpdateOptions{})
+	return err
+}
+
+func registerValidatingWebhook(client kubernetes.Interface, wc config.WebhookConfig, caBundle []byte) error {
+	vwcClient := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	vwc, err := vwcClient.Get(context.TODO(), wc.ValidatingWebhookConfiguration, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		vwc = &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: wc.ValidatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{validatingWebhook(wc, caBundle)},
+		}
+		klog.Infof("creating validating webhook configuration %s", vwc.Name)
+		_, err = vwcClient.Create(context.TODO(), vwc, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return apierrors.NewConflict(admissionregistrationv1.Resource("validatingwebhookconfigurations"), vwc.Name, err)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if caBundle == nil {
+		for _, w := range vwc.Webhooks {
+			if w.Name == ValidatingWebhookName {
+				caBundle = w.ClientConfig.CABundle
+			}
+		}
+	}
+	webhooks := []admissionregistrationv1.ValidatingWebhook{validatingWebhook(wc, caBundle)}
+	vwc = vwc.DeepCopy()
+	for i := range vwc.Webhooks {
+		setDefaultValidatingWebhook(&vwc.Webhooks[i])
+	}
+	setDefaultValidatingWebhook(&webhooks[0])
+	if apiequality.Semantic.DeepEqual(vwc.Webhooks, webhooks) {
+		return nil
+	}
+	vwc.Webhooks = webhooks
+	klog.Infof("updating validating webhook configuration %s", vwc.Name)
+	_, err = vwcClient.Update(context.TODO(), vwc, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRegisterWebhooks(t *testing.T) {
+	wc := config.Default().Webhook
+	client := fake.NewSimpleClientset()
+	caBundle := []byte("ca")
+
+	if err := registerWebhooks(client, wc, caBundle); err != nil {
+		t.Fatal(err)
+	}
+
+	mwc, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.TODO(), wc.MutatingWebhookConfiguration, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *mwc.Webhooks[0].ClientConfig.Service.Path != MutatePath {
+		t.Errorf("expect %v, but %v returned", MutatePath, *mwc.Webhooks[0].ClientConfig.Service.Path)
+	}
+	if mwc.Webhooks[0].Rules[0].APIGroups[0] != "" {
+		t.Errorf("expect core api group, but %v returned", mwc.Webhooks[0].Rules[0].APIGroups[0])
+	}
+
+	vwc, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), wc.ValidatingWebhookConfiguration, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *vwc.Webhooks[0].ClientConfig.Service.Path != ValidatePath {
+		t.Errorf("expect %v, but %v returned", ValidatePath, *vwc.Webhooks[0].ClientConfig.Service.Path)
+	}
+	resources := []string{}
+	for _, r := range vwc.Webhooks[0].Rules {
+		resources = append(resources, r.Resources...)
+	}
+	if len(resources) != 2 || resources[0] != "pods" || resources[1] != "pods/eviction" {
+		t.Errorf("expect %v, but %v returned", []string{"pods", "pods/eviction"}, resources)
+	}
+
+	// a nil caBundle keeps the registered one
+	wc.TimeoutSeconds = 5
+	if err := registerWebhooks(client, wc, nil); err != nil {
+		t.Fatal(err)
+	}
+	vwc, err = client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), wc.ValidatingWebhookConfiguration, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *vwc.Webhooks[0].TimeoutSeconds != 5 {
+		t.Errorf("expect %v, but %v returned", 5, *vwc.Webhooks[0].TimeoutSeconds)
+	}
+	if !bytes.Equal(vwc.Webhooks[0].ClientConfig.CABundle, caBundle) {
+		t.Errorf("expect caBundle kept, but %s returned", vwc.Webhooks[0].ClientConfig.CABundle)
+	}
+}
+
+func TestRegisterWebhooksDefaulted(t *testing.T) {
+	wc := config.Default().Webhook
+	caBundle := []byte("ca")
+
+	// as the API server returns them, with its defaults applied
+	equivalent := admissionregistrationv1.Equivalent
+	never := admissionregistrationv1.NeverReinvocationPolicy
+	mw := mutatingWebhook(wc, caBundle)
+	mw.MatchPolicy = &equivalent
+	mw.ReinvocationPolicy = &never
+	mw.NamespaceSelector = &metav1.LabelSelector{}
+	vw := validatingWebhook(wc, caBundle)
+	vw.MatchPolicy = &equivalent
+	vw.NamespaceSelector = &metav1.LabelSelector{}
+
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: wc.MutatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.MutatingWebhook{mw},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: wc.ValidatingWebhookConfiguration},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{vw},
+		},
+	)
+	if err := registerWebhooks(client, wc, caBundle); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range client.Actions() {
+		if a.GetVerb() != "get" {
+			t.Errorf("expect only gets, but %s %s returned", a.GetVerb(), a.GetResource().Resource)
+		}
+	}
+
+	// the controller's own pods are never selected
+	expressions := mw.ObjectSelector.MatchExpressions
+	if len(expressions) != 1 || expressions[0].Key != labelKeyName ||
+		expressions[0].Operator != metav1.LabelSelectorOpNotIn || expressions[0].Values[0] != wc.SelfName {
+		t.Errorf("expect %s notin (%s), but %+v returned", labelKeyName, wc.SelfName, expressions)
+	}
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+// poolThrottle is the eviction token bucket of a pool. Its rate and burst change as the pool
+// enters or leaves partial disruption, the tokens left are kept.
+type poolThrottle struct {
+	limiter *rate.Limiter
+	partial bool
+}
+
+var (
+	throttles    = map[string]*poolThrottle{}
+	throttleLock sync.Mutex
+)
+
+// evictionRate returns the limit and burst of a pool, by whether it is partially disrupted.
+// A partially disrupted pool gets no burst beyond a single eviction.
+func evictionRate(partial bool) (float64, int) {
+	if partial {
+		return cfg.Throttle.PartialDisruptionEvictionsPerMinute, 1
+	}
+	return cfg.Throttle.EvictionsPerMinute, cfg.Throttle.Burst
+}
+
+// partiallyDisrupted tells whether too few nodes of pool are alive for the primary rate
+func partiallyDisrupted(pool string) bool {
+	s := health.Stats(pool)
+	return s.Nodes > 0 && float64(s.Alive)/float64(s.Nodes) < cfg.Throttle.PartialDisruptionAliveRatio
+}
+
+// tryAcceptEviction takes a token from the eviction bucket of pool, it returns false if
+// the pool evicts too fast already
+func tryAcceptEviction(pool string) bool {
+	partial := partiallyDisrupted(pool)
+	perMinute, burst := evictionRate(partial)
+	now := time.Now()
+
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+
+	t, ok := throttles[pool]
+	switch {
+	case !ok:
+		t = &poolThrottle{limiter: rate.NewLimiter(rate.Limit(perMinute/60), burst), partial: partial}
+		throttles[pool] = t
+	case t.partial != partial:
+		klog.Infof("nodepool %s partially disrupted %v, evicting %v pods per minute", pool, partial, perMinute)
+		t.limiter.SetLimitAt(now, rate.Limit(perMinute/60))
+		t.limiter.SetBurstAt(now, burst)
+		t.partial = partial
+	}
+	// rate.Limiter treats a zero limit as a budget of burst events, a rate of 0 approves none
+	if perMinute == 0 {
+		return false
+	}
+	return t.limiter.AllowN(now, 1)
+}
+
+// retryAfterSeconds tells clients denied by the throttle of pool when to try again
+func retryAfterSeconds(pool string) int32 {
+	perMinute, _ := evictionRate(partiallyDisrupted(pool))
+	if perMinute == 0 {
+		return 60
+	}
+	return int32(math.Ceil(60 / perMinute))
+}
+
+// resetThrottles forgets the eviction limiters of every pool
+func resetThrottles() {
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+
+	throttles = map[string]*poolThrottle{}
+}
+
+// throttle denies the approved deletion val of the pod if its pool evicts too fast already
+func (pv *PodAdmission) throttle(val validation) validation {
+	pool := pv.pool()
+	if !cfg.Throttle.Enabled || pool == "" || nodeIsCloud(pv.node) {
+		return val
+	}
+	// requests not persisted take no token from real evictions
+	if pv.isDryRunRequest() {
+		return val
+	}
+	if tryAcceptEviction(pool) {
+		return val
+	}
+	klog.Infof("deletion of pod %s/%s throttled in nodepool %s", pv.pod.Namespace, pv.pod.Name, pool)
+	return validation{Valid: false, Reason: msgEvictionThrottled, RetryAfterSeconds: retryAfterSeconds(pool)}
+}
@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/openyurt/pkg/controller/poolcoordinator/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestThrottleEvictions(t *testing.T) {
+	cfg = config.Default()
+	cfg.Throttle.EvictionsPerMinute = 1
+	cfg.Throttle.PartialDisruptionEvictionsPerMinute = 0
+	cfg.Throttle.Burst = 2
+
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2", "node3", "node4"} {
+		nodes = append(nodes, poolNode(name, "pool1", nil))
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+
+	tests := []struct {
+		name    string
+		alive   int
+		allowed []bool
+	}{
+		{"primary rate", 4, []bool{true, true, false}},
+		{"partial disruption", 1, []bool{false}},
+	}
+	for _, tt := range tests {
+		setupListers(t, nodes, []*corev1.Pod{pod})
+		for i, n := range nodes {
+			renewed := time.Now()
+			if i >= tt.alive {
+				renewed = renewed.Add(-time.Minute)
+			}
+			observeLease(n.Name, renewed)
+		}
+		syncHealth(t)
+
+		for i, allowed := range tt.allowed {
+			pv := &PodAdmission{
+				request: evictionRequest(t, "default", "pod1"),
+				pod:     &corev1.Pod{},
+			}
+			out, err := pv.validateReview()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Response.Allowed != allowed {
+				t.Errorf("%s #%d: expect %v, but %v returned", tt.name, i, allowed, out.Response.Allowed)
+			}
+			if allowed {
+				continue
+			}
+			if out.Response.Result.Code != http.StatusTooManyRequests || out.Response.Result.Message != msgEvictionThrottled {
+				t.Errorf("%s #%d: expect %v %v, but %v %v returned", tt.name, i,
+					http.StatusTooManyRequests, msgEvictionThrottled, out.Response.Result.Code, out.Response.Result.Message)
+			}
+			if d := out.Response.Result.Details; d == nil || d.RetryAfterSeconds != 60 {
+				t.Errorf("%s #%d: expect retry after %v, but %+v returned", tt.name, i, 60, d)
+			}
+		}
+	}
+}
+
+func TestThrottleFlappingPool(t *testing.T) {
+	cfg = config.Default()
+	cfg.Throttle.EvictionsPerMinute = 1
+	cfg.Throttle.PartialDisruptionEvictionsPerMinute = 6
+	cfg.Throttle.Burst = 2
+
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2", "node3", "node4"} {
+		nodes = append(nodes, poolNode(name, "pool1", nil))
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	setupListers(t, nodes, []*corev1.Pod{pod})
+	for _, n := range nodes {
+		observeLease(n.Name, time.Now())
+	}
+	syncHealth(t)
+
+	// partial flips the pool in and out of partial disruption, node1 keeps alive
+	partial := func(disrupted bool) {
+		for _, n := range nodes[1:] {
+			health.Set(n.Name, "pool1", !disrupted, false)
+		}
+	}
+	tests := []struct {
+		name      string
+		disrupted bool
+		allowed   bool
+	}{
+		{"first of burst", false, true},
+		{"partial, token left", true, true},
+		{"partial, no burst", true, false},
+		{"primary, not refilled", false, false},
+		{"partial again, not refilled", true, false},
+	}
+	for _, tt := range tests {
+		partial(tt.disrupted)
+		pv := &PodAdmission{
+			request: evictionRequest(t, "default", "pod1"),
+			pod:     &corev1.Pod{},
+		}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+	}
+}
+
+func TestThrottleDryRunRequest(t *testing.T) {
+	cfg = config.Default()
+	cfg.Throttle.EvictionsPerMinute = 1
+	cfg.Throttle.Burst = 1
+
+	nodes := []*corev1.Node{}
+	for _, name := range []string{"node1", "node2"} {
+		nodes = append(nodes, poolNode(name, "pool1", nil))
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+	}
+	setupListers(t, nodes, []*corev1.Pod{pod})
+	for _, n := range nodes {
+		observeLease(n.Name, time.Now())
+	}
+	syncHealth(t)
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		allowed bool
+	}{
+		{"dry-run", true, true},
+		{"dry-run again", true, true},
+		{"real, token left", false, true},
+		{"real, throttled", false, false},
+	}
+	for _, tt := range tests {
+		req := evictionRequest(t, "default", "pod1")
+		req.DryRun = &tt.dryRun
+		pv := &PodAdmission{request: req, pod: &corev1.Pod{}}
+		out, err := pv.validateReview()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Response.Allowed != tt.allowed {
+			t.Errorf("%s: expect %v, but %v returned", tt.name, tt.allowed, out.Response.Allowed)
+		}
+	}
+}